@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// splitTargetZone removes an IPv6 zone from a target, which may appear on the
+// address ("fe80::1%eth0/64") or after the prefix ("fe80::/64%eth0")
+func splitTargetZone(target string) (string, string) {
+	addr, prefix, hasPrefix := strings.Cut(target, "/")
+	addr, zone, _ := strings.Cut(addr, "%")
+	if hasPrefix {
+		prefix, prefixZone, _ := strings.Cut(prefix, "%")
+		return addr + "/" + prefix, cmpZone(zone, prefixZone)
+	}
+	return addr, zone
+}
+
+// isHostnameTarget reports whether a target is a name to resolve rather than
+// an address or CIDR
+func isHostnameTarget(target string) bool {
+	if strings.Contains(target, "/") {
+		return false
+	}
+	addr, _ := splitTargetZone(target)
+	_, err := netip.ParseAddr(addr)
+	return err != nil
+}
+
+// cmpZone returns the first non-empty zone
+func cmpZone(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+// parseTarget parses a CIDR or a single address, with an optional IPv6 zone,
+// into a network. IPv4-mapped IPv6 networks are converted to plain IPv4 so
+// every address is scanned, reported and sorted in one canonical form.
+func parseTarget(target string) (*net.IPNet, string, error) {
+	cidr, zone := splitTargetZone(target)
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return nil, "", err
+		}
+		cidr = netip.PrefixFrom(addr, addr.BitLen()).String()
+	}
+
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if ip4 := ipNet.IP.To4(); ip4 != nil && bits == 128 && ones >= 96 {
+		ipNet = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 32)}
+	}
+	if zone != "" && ipNet.IP.To4() != nil {
+		return nil, "", fmt.Errorf("zone %q is only valid on IPv6 targets", zone)
+	}
+	return ipNet, zone, nil
+}
+
+// withZone appends an IPv6 zone to an address string
+func withZone(ip, zone string) string {
+	if zone == "" {
+		return ip
+	}
+	return ip + "%" + zone
+}
+
+// parseTargetIP parses a scanned address, keeping its zone, and unmaps
+// IPv4-mapped IPv6 addresses
+func parseTargetIP(ip string) (*net.IPAddr, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, false
+	}
+	addr = addr.Unmap()
+	return &net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}, true
+}
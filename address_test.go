@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestSplitTargetZone(t *testing.T) {
+	tests := []struct {
+		target, addr, zone string
+	}{
+		{"fe80::1%eth0", "fe80::1", "eth0"},
+		{"fe80::/64%eth0", "fe80::/64", "eth0"},
+		{"fe80::%eth0/64", "fe80::/64", "eth0"},
+		{"192.168.1.0/24", "192.168.1.0/24", ""},
+		{"fe80::1", "fe80::1", ""},
+	}
+
+	for _, tt := range tests {
+		addr, zone := splitTargetZone(tt.target)
+		if addr != tt.addr || zone != tt.zone {
+			t.Errorf("splitTargetZone(%q) = %q, %q; want %q, %q", tt.target, addr, zone, tt.addr, tt.zone)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		network string
+		zone    string
+		wantErr bool
+	}{
+		{target: "fe80::1%eth0", network: "fe80::1/128", zone: "eth0"},
+		{target: "fe80::/64%eth0", network: "fe80::/64", zone: "eth0"},
+		{target: "fe80::%eth0/64", network: "fe80::/64", zone: "eth0"},
+		{target: "::ffff:10.0.0.0/120", network: "10.0.0.0/24"},
+		{target: "::ffff:10.0.0.1", network: "10.0.0.1/32"},
+		{target: "192.168.1.7/24", network: "192.168.1.0/24"},
+		{target: "192.168.1.7", network: "192.168.1.7/32"},
+		{target: "192.168.1.0/24%eth0", wantErr: true},
+		{target: "192.168.1.1%eth0", wantErr: true},
+		{target: "::ffff:10.0.0.0/120%eth0", wantErr: true},
+		{target: "not-an-ip", wantErr: true},
+		{target: "10.0.0.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		ipNet, zone, err := parseTarget(tt.target)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTarget(%q) = %s, %q; want an error", tt.target, ipNet, zone)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTarget(%q) failed: %v", tt.target, err)
+			continue
+		}
+		if ipNet.String() != tt.network || zone != tt.zone {
+			t.Errorf("parseTarget(%q) = %s, %q; want %s, %q", tt.target, ipNet, zone, tt.network, tt.zone)
+		}
+	}
+}
+
+func TestParseTargetIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string // As net.IPAddr.String formats it, with the zone
+		ok   bool
+	}{
+		{"fe80::1%eth0", "fe80::1%eth0", true},
+		{"::ffff:10.0.0.1", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.1", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"fe80::1%", "", false},
+		{"host.example", "", false},
+	}
+
+	for _, tt := range tests {
+		addr, ok := parseTargetIP(tt.ip)
+		if ok != tt.ok || (ok && addr.String() != tt.want) {
+			t.Errorf("parseTargetIP(%q) = %v, %v; want %s, %v", tt.ip, addr, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsHostnameTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"router.lan", true},
+		{"fe80::1%eth0", false},
+		{"192.168.1.1", false},
+		{"192.168.1.0/24", false},
+	}
+
+	for _, tt := range tests {
+		if got := isHostnameTarget(tt.target); got != tt.want {
+			t.Errorf("isHostnameTarget(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
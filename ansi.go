@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// colorOutput returns w for a terminal, and otherwise a writer that strips
+// the colors of the table output, so files saved with -output-dir and piped
+// output hold plain text
+func colorOutput(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return w
+	}
+	return &plainWriter{w: w}
+}
+
+// plainWriter drops the ANSI color escapes (ESC [ ... final byte) of the
+// table output before passing the text on to w. An escape may be split
+// across writes.
+type plainWriter struct {
+	w      io.Writer
+	escape int // 0 outside an escape, 1 after ESC, 2 inside its parameters
+}
+
+func (p *plainWriter) Write(b []byte) (int, error) {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch {
+		case p.escape == 0 && c == 0x1b:
+			p.escape = 1
+		case p.escape == 0:
+			out = append(out, c)
+		case p.escape == 1 && c == '[':
+			p.escape = 2
+		case p.escape == 1:
+			// Not a CSI sequence; keep the byte after ESC
+			p.escape = 0
+			out = append(out, c)
+		case c >= 0x40 && c <= 0x7e:
+			p.escape = 0 // Final byte of the sequence
+		}
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// baseline is a saved IP to MAC map used to spot impersonation: an IP whose
+// MAC changed, or a MAC never seen before on the network
+type baseline map[string]string
+
+// loadBaseline reads a baseline file. A missing file is not an error; it
+// returns a nil baseline so the caller can create one.
+func loadBaseline(path string) (baseline, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var b baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// saveBaseline writes the IP to MAC map of the hosts that have a known MAC
+func saveBaseline(path string, hosts []HostInfo) (int, error) {
+	b := make(baseline)
+	for _, host := range hosts {
+		if host.MAC != "" {
+			b[host.IP] = host.MAC
+		}
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return 0, fmt.Errorf("failed to save baseline: %w", err)
+	}
+	return len(b), nil
+}
+
+// annotate adds a note to every host whose MAC differs from the baseline or
+// was never seen in it, and returns how many hosts were flagged
+func (b baseline) annotate(hosts []HostInfo) int {
+	known := make(map[string]bool, len(b))
+	for _, mac := range b {
+		known[mac] = true
+	}
+
+	flagged := 0
+	for i := range hosts {
+		host := &hosts[i]
+		if host.MAC == "" {
+			continue
+		}
+
+		if previous, ok := b[host.IP]; ok && previous != host.MAC {
+			host.Notes = append(host.Notes, fmt.Sprintf("MAC changed (was %s)", previous))
+			flagged++
+		} else if !known[host.MAC] {
+			host.Notes = append(host.Notes, "new MAC")
+			flagged++
+		}
+	}
+	return flagged
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resolvConfPaths lists the resolver configuration files read for the system
+// DNS servers. systemd-resolved points resolv.conf at its local stub, so its
+// own file holds the servers queries are forwarded to.
+var resolvConfPaths = []string{"/run/systemd/resolve/resolv.conf", "/etc/resolv.conf"}
+
+// dnsLeaks records reverse lookups of private addresses that go to a public
+// DNS server, where they reveal the scanned network
+type dnsLeaks struct {
+	server string // Public server lookups are sent to
+	block  bool   // Skip the lookups instead of only reporting them
+
+	mu  sync.Mutex
+	ips []string
+}
+
+// check reports whether the reverse lookup of ip may be sent, recording it
+// when it would leak a private address
+func (l *dnsLeaks) check(ip string) bool {
+	if l == nil || l.server == "" || !isPrivateAddr(ip) {
+		return true
+	}
+
+	l.mu.Lock()
+	l.ips = append(l.ips, ip)
+	l.mu.Unlock()
+	return !l.block
+}
+
+// take returns the addresses recorded since the last call and forgets them
+func (l *dnsLeaks) take() []string {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ips := l.ips
+	l.ips = nil
+	slices.SortFunc(ips, func(a, b string) int {
+		if lessIP(a, b) {
+			return -1
+		}
+		return 1
+	})
+	return ips
+}
+
+// isPrivateAddr reports whether ip is an address that means nothing outside
+// the local network: RFC 1918, unique local, link-local or loopback
+func isPrivateAddr(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && (addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLoopback())
+}
+
+// publicDNSServer returns the first DNS server r sends queries to that is not
+// on a private network, or "" if none is or the servers are unknown
+func publicDNSServer(r resolver) string {
+	var servers []string
+	if doh, ok := r.(*dohResolver); ok {
+		if u, err := url.Parse(doh.url); err == nil {
+			servers = []string{u.Hostname()}
+		}
+	} else {
+		servers = systemDNSServers()
+	}
+
+	for _, server := range servers {
+		if !privateHost(server) {
+			return server
+		}
+	}
+	return ""
+}
+
+// privateHost reports whether a DNS server, given as an address or a name,
+// is on a private network
+func privateHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return isPrivateAddr(host)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	return slices.ContainsFunc(addrs, isPrivateAddr)
+}
+
+// systemDNSServers returns the nameservers of the first resolver
+// configuration file that lists any. It returns nil where there is no
+// resolv.conf, such as on Windows.
+func systemDNSServers() []string {
+	for _, path := range resolvConfPaths {
+		if servers := readNameservers(path); len(servers) > 0 {
+			return servers
+		}
+	}
+	return nil
+}
+
+// readNameservers returns the nameserver addresses in a resolv.conf file
+func readNameservers(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			// Link-local servers may carry a zone, which ParseIP rejects
+			server, _, _ := strings.Cut(fields[1], "%")
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// leakSummaryLimit is how many leaked addresses are listed in a warning
+const leakSummaryLimit = 10
+
+// summarizeLeaks lists up to leakSummaryLimit addresses for a warning
+func summarizeLeaks(ips []string) string {
+	if len(ips) <= leakSummaryLimit {
+		return strings.Join(ips, ", ")
+	}
+	return strings.Join(ips[:leakSummaryLimit], ", ") + ", ..."
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohMediaType is the DNS wire format content type from RFC 8484
+const dohMediaType = "application/dns-message"
+
+// dohMaxResponse bounds the DNS response read from a DoH server
+const dohMaxResponse = 64 << 10
+
+// dohResolver resolves names over DNS-over-HTTPS (RFC 8484), for networks
+// where plain DNS is blocked or untrusted
+type dohResolver struct {
+	url    string
+	client *http.Client
+}
+
+// newDoHResolver returns a resolver that POSTs queries to the DoH endpoint
+// at rawURL (e.g. https://cloudflare-dns.com/dns-query)
+func newDoHResolver(rawURL string) (*dohResolver, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 8484 requires HTTPS; plain http would expose every lookup
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("DoH URL %q must be an https:// URL", rawURL)
+	}
+	return &dohResolver{url: rawURL, client: &http.Client{}}, nil
+}
+
+// LookupAddr returns the PTR names of addr
+func (r *dohResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, err := r.query(ctx, name, dnsmessage.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, answer := range answers {
+		if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
+			names = append(names, ptr.PTR.String())
+		}
+	}
+	if len(names) == 0 {
+		return nil, &net.DNSError{Err: "no PTR record", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
+
+// LookupNetIP returns the A and/or AAAA addresses of host, depending on
+// whether network is "ip", "ip4" or "ip6"
+func (r *dohResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	var types []dnsmessage.Type
+	switch network {
+	case "ip":
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	var addrs []netip.Addr
+	for _, qtype := range types {
+		answers, err := r.query(ctx, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				addrs = append(addrs, netip.AddrFrom4(body.A))
+			case *dnsmessage.AAAAResource:
+				addrs = append(addrs, netip.AddrFrom16(body.AAAA))
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// query sends one DNS question to the DoH server and returns the answers
+func (r *dohResolver) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8484 asks for ID 0 so responses stay cacheable
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: r.url, IsTimeout: ctx.Err() != nil}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &net.DNSError{Err: fmt.Sprintf("DoH server returned status %d", resp.StatusCode), Name: name, Server: r.url}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dohMaxResponse))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: r.url}
+	}
+
+	var reply dnsmessage.Message
+	if err := reply.Unpack(body); err != nil {
+		return nil, &net.DNSError{Err: "malformed DoH response: " + err.Error(), Name: name, Server: r.url}
+	}
+	switch reply.RCode {
+	case dnsmessage.RCodeSuccess:
+		return reply.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.url, IsNotFound: true}
+	}
+	return nil, &net.DNSError{Err: "DoH server answered " + reply.RCode.String(), Name: name, Server: r.url}
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name for a PTR lookup of addr
+func reverseName(addr string) (string, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return "", err
+	}
+	ip = ip.Unmap()
+
+	var b strings.Builder
+	if ip.Is4() {
+		octets := ip.As4()
+		for i := len(octets) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "%d.", octets[i])
+		}
+		b.WriteString("in-addr.arpa.")
+		return b.String(), nil
+	}
+
+	const hex = "0123456789abcdef"
+	octets := ip.As16()
+	for i := len(octets) - 1; i >= 0; i-- {
+		b.WriteByte(hex[octets[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hex[octets[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String(), nil
+}
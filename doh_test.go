@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestNewDoHResolver(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://cloudflare-dns.com/dns-query", false},
+		{"http://cloudflare-dns.com/dns-query", true},
+		{"https:///dns-query", true},
+		{"cloudflare-dns.com/dns-query", true},
+	}
+
+	for _, tt := range tests {
+		if _, err := newDoHResolver(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("newDoHResolver(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"192.168.1.20", "20.1.168.192.in-addr.arpa."},
+		{"::ffff:10.0.0.1", "1.0.0.10.in-addr.arpa."},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+
+	for _, tt := range tests {
+		got, err := reverseName(tt.addr)
+		if err != nil || got != tt.want {
+			t.Errorf("reverseName(%q) = %q, %v; want %q", tt.addr, got, err, tt.want)
+		}
+	}
+
+	if _, err := reverseName("not-an-ip"); err == nil {
+		t.Error("reverseName(\"not-an-ip\") succeeded, want an error")
+	}
+}
+
+// dohServer answers every query with rcode and a PTR record for each of ptrs
+func dohServer(t *testing.T, rcode dnsmessage.RCode, ptrs ...string) *dohResolver {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if r.Header.Get("Content-Type") != dohMediaType || query.Unpack(body) != nil || len(query.Questions) != 1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+
+		q := query.Questions[0]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true, RCode: rcode},
+			Questions: query.Questions,
+		}
+		for _, ptr := range ptrs {
+			reply.Answers = append(reply.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(ptr)},
+			})
+		}
+		packed, err := reply.Pack()
+		if err != nil {
+			t.Errorf("packing reply: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(packed)
+	}))
+	t.Cleanup(srv.Close)
+	return &dohResolver{url: srv.URL, client: srv.Client()}
+}
+
+func TestDoHLookupAddr(t *testing.T) {
+	r := dohServer(t, dnsmessage.RCodeSuccess, "router.lan.", "gw.lan.")
+	names, err := r.LookupAddr(context.Background(), "192.168.1.1")
+	if err != nil || !slices.Equal(names, []string{"router.lan.", "gw.lan."}) {
+		t.Errorf("LookupAddr() = %v, %v; want [router.lan. gw.lan.]", names, err)
+	}
+
+	tests := []struct {
+		name string
+		r    *dohResolver
+	}{
+		{"no PTR record", dohServer(t, dnsmessage.RCodeSuccess)},
+		{"NXDOMAIN", dohServer(t, dnsmessage.RCodeNameError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.r.LookupAddr(context.Background(), "192.168.1.1")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				t.Errorf("LookupAddr() error = %v, want a not-found DNSError", err)
+			}
+		})
+	}
+
+	r = dohServer(t, dnsmessage.RCodeServerFailure)
+	var dnsErr *net.DNSError
+	if _, err := r.LookupAddr(context.Background(), "192.168.1.1"); !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+		t.Errorf("LookupAddr() error = %v on SERVFAIL, want a DNSError that is not not-found", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build darwin
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment sets the IPv4 don't-fragment bit on every packet sent from c
+func setDontFragment(c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_DONTFRAG, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// setDontFragment is not implemented on this platform
+func setDontFragment(c syscall.RawConn) error {
+	return fmt.Errorf("setting the don't-fragment bit is not supported on %s", runtime.GOOS)
+}
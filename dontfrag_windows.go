@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// IP_DONTFRAGMENT from ws2ipdef.h
+const ipDontFragment = 14
+
+// setDontFragment sets the IPv4 don't-fragment bit on every packet sent from c
+func setDontFragment(c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipDontFragment, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
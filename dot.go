@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writeDOT renders the scan as a Graphviz graph, with the scanning host at the
+// center connected to every discovered host. Pipe it through "dot -Tpng" for a
+// simple network map.
+func writeDOT(w io.Writer, result *ScanResult, macFormat string) error {
+	self, err := os.Hostname()
+	if err != nil || self == "" {
+		self = "neti"
+	}
+
+	bw := bufio.NewWriter(w)
+	if result.Meta != nil {
+		fmt.Fprintf(bw, "// %s\n", result.Meta)
+		if result.Meta.Self != nil {
+			fmt.Fprintf(bw, "// %s\n", result.Meta.Self.withMACFormat(macFormat))
+		}
+	}
+	fmt.Fprintln(bw, "graph neti {")
+	fmt.Fprintln(bw, "\tlayout=twopi;")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"Helvetica\"];")
+	fmt.Fprintf(bw, "\tscanner [label=%s, shape=doubleoctagon, root=true];\n", strconv.Quote(self))
+
+	for i, host := range result.ReachableHosts {
+		label := []string{host.IP}
+		if host.Label != "" {
+			label = append(label, host.Label)
+		}
+		if host.Hostname != "" {
+			label = append(label, host.Hostname)
+		}
+		if vendor := mac2manufacturer(host.MAC); vendor != "" {
+			label = append(label, vendor)
+		}
+
+		id := fmt.Sprintf("host%d", i+1)
+		if !host.Up {
+			fmt.Fprintf(bw, "\t%s [label=%s, style=dashed, fontcolor=gray];\n", id, strconv.Quote(strings.Join(label, "\n")))
+			fmt.Fprintf(bw, "\tscanner -- %s [style=dotted];\n", id)
+			continue
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s];\n", id, strconv.Quote(strings.Join(label, "\n")))
+		fmt.Fprintf(bw, "\tscanner -- %s;\n", id)
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors returned by the scanner and the OUI database. They wrap the
+// underlying cause, so check for them with errors.Is.
+var (
+	ErrInvalidSubnet  = errors.New("invalid subnet")
+	ErrNoRawSocket    = errors.New("raw ICMP socket unavailable (run as root or grant CAP_NET_RAW)")
+	ErrOUIUnavailable = errors.New("OUI database unavailable")
+)
+
+// SubnetError is a requested subnet that could not be scanned
+type SubnetError struct {
+	Subnet string
+	Err    error
+}
+
+func (e *SubnetError) Error() string { return e.Subnet + ": " + e.Err.Error() }
+
+func (e *SubnetError) Unwrap() error { return e.Err }
+
+// SubnetErrors lists every subnet skipped by a scan that continued past them
+type SubnetErrors []*SubnetError
+
+func (e SubnetErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e SubnetErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// hostFilter reports whether a host should be kept in the results
+type hostFilter func(host HostInfo) bool
+
+// applyFilters keeps only the hosts accepted by every filter and records how
+// many were dropped
+func applyFilters(result *ScanResult, filters []hostFilter) {
+	if len(filters) == 0 {
+		return
+	}
+
+	// Counted before kept overwrites the hosts it compacts in place
+	before := onlineHosts(result.ReachableHosts)
+	kept := result.ReachableHosts[:0]
+	for _, host := range result.ReachableHosts {
+		if acceptHost(host, filters) {
+			kept = append(kept, host)
+		}
+	}
+
+	result.Filtered += before - onlineHosts(kept)
+	result.ReachableHosts = kept
+}
+
+// acceptHost reports whether every filter accepts the host
+func acceptHost(host HostInfo, filters []hostFilter) bool {
+	for _, filter := range filters {
+		if !filter(host) {
+			return false
+		}
+	}
+	return true
+}
+
+// vendorFilter keeps hosts whose manufacturer contains substr, ignoring case
+func vendorFilter(substr string) hostFilter {
+	substr = strings.ToLower(substr)
+	return func(host HostInfo) bool {
+		return strings.Contains(strings.ToLower(mac2manufacturer(host.MAC)), substr)
+	}
+}
+
+// rttFilter keeps hosts whose ICMP response time is within [low, high]. A zero
+// bound is open. Hosts that never answered ICMP have no RTT and are dropped.
+func rttFilter(low, high time.Duration) hostFilter {
+	return func(host HostInfo) bool {
+		rtt := host.ICMPResponseTime
+		if rtt <= 0 {
+			return false
+		}
+		return (low == 0 || rtt >= low) && (high == 0 || rtt <= high)
+	}
+}
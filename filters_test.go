@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyFilters(t *testing.T) {
+	up := func(ip string, rtt time.Duration) HostInfo {
+		return HostInfo{IP: ip, Up: true, ICMPResponseTime: rtt}
+	}
+	down := func(ip string) HostInfo { return HostInfo{IP: ip} }
+
+	fast := rttFilter(0, 5*time.Millisecond)
+	tests := []struct {
+		name         string
+		hosts        []HostInfo
+		filters      []hostFilter
+		want         []string
+		wantFiltered int
+	}{
+		{"no filters", []HostInfo{up("10.0.0.1", time.Millisecond), down("10.0.0.2")}, nil, []string{"10.0.0.1", "10.0.0.2"}, 0},
+		{"all kept", []HostInfo{up("10.0.0.1", time.Millisecond), up("10.0.0.2", 2*time.Millisecond)}, []hostFilter{fast}, []string{"10.0.0.1", "10.0.0.2"}, 0},
+		{"slow host dropped", []HostInfo{up("10.0.0.1", time.Millisecond), up("10.0.0.2", 10*time.Millisecond)}, []hostFilter{fast}, []string{"10.0.0.1"}, 1},
+		// Down hosts are dropped too, but were never online, so they are not counted
+		{"down host before a kept one", []HostInfo{down("10.0.0.1"), up("10.0.0.2", time.Millisecond)}, []hostFilter{fast}, []string{"10.0.0.2"}, 0},
+		{"down and slow hosts", []HostInfo{down("10.0.0.1"), up("10.0.0.2", time.Millisecond), up("10.0.0.3", 10*time.Millisecond), down("10.0.0.4")}, []hostFilter{fast}, []string{"10.0.0.2"}, 1},
+		{"offline host", []HostInfo{{IP: "10.0.0.1", Up: true, Offline: true}, up("10.0.0.2", time.Millisecond)}, []hostFilter{fast}, []string{"10.0.0.2"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ScanResult{ReachableHosts: tt.hosts}
+			applyFilters(result, tt.filters)
+
+			var got []string
+			for _, host := range result.ReachableHosts {
+				got = append(got, host.IP)
+			}
+			if !slices.Equal(got, tt.want) || result.Filtered != tt.wantFiltered {
+				t.Errorf("kept %v with %d filtered, want %v with %d", got, result.Filtered, tt.want, tt.wantFiltered)
+			}
+		})
+	}
+}
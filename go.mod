@@ -7,11 +7,11 @@ require golang.org/x/net v0.41.0
 require (
 	github.com/jedib0t/go-pretty/v6 v6.6.7
 	golang.org/x/sys v0.34.0
+	golang.org/x/term v0.32.0
 )
 
 require (
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/term v0.32.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
 )
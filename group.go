@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Result grouping modes for -group-by
+const (
+	GroupByVendor = "vendor"
+	GroupBySubnet = "subnet"
+)
+
+// hostGroup is a named set of hosts rendered together
+type hostGroup struct {
+	Name  string
+	Hosts []HostInfo
+}
+
+// validateGroupBy checks that the requested grouping mode is supported
+func validateGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", GroupByVendor, GroupBySubnet:
+		return nil
+	}
+	return fmt.Errorf("unsupported grouping %q (use %s or %s)", groupBy, GroupByVendor, GroupBySubnet)
+}
+
+// groupTitle returns the column heading for a grouping mode
+func groupTitle(groupBy string) string {
+	if groupBy == GroupBySubnet {
+		return "Subnet"
+	}
+	return "Manufacturer"
+}
+
+// groupHosts splits hosts into groups, keeping the host order inside each group.
+// Vendor groups are sorted by name with unknown vendors last; subnet groups
+// follow the order the subnets were given in.
+func groupHosts(hosts []HostInfo, groupBy string, subnets []string) []hostGroup {
+	var names []string
+	members := make(map[string][]HostInfo)
+
+	var nets []*net.IPNet
+	if groupBy == GroupBySubnet {
+		for _, subnet := range subnets {
+			if _, ipNet, err := net.ParseCIDR(subnet); err == nil {
+				nets = append(nets, ipNet)
+				names = append(names, ipNet.String())
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		var name string
+		if groupBy == GroupBySubnet {
+			name = subnetOf(host.IP, nets)
+		} else {
+			name = mac2manufacturer(host.MAC)
+		}
+		if name == "" {
+			name = "N/A"
+		}
+
+		if _, ok := members[name]; !ok && groupBy != GroupBySubnet {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], host)
+	}
+
+	if groupBy != GroupBySubnet {
+		sort.Slice(names, func(i, j int) bool {
+			if (names[i] == "N/A") != (names[j] == "N/A") {
+				return names[j] == "N/A"
+			}
+			return names[i] < names[j]
+		})
+	} else if _, ok := members["N/A"]; ok {
+		names = append(names, "N/A")
+	}
+
+	var groups []hostGroup
+	for _, name := range names {
+		if len(members[name]) > 0 {
+			groups = append(groups, hostGroup{Name: name, Hosts: members[name]})
+		}
+	}
+	return groups
+}
+
+// subnetOf returns the first subnet containing ip, or "" if none does
+func subnetOf(ip string, nets []*net.IPNet) string {
+	parsed := net.ParseIP(ip)
+	for _, ipNet := range nets {
+		if parsed != nil && ipNet.Contains(parsed) {
+			return ipNet.String()
+		}
+	}
+	return ""
+}
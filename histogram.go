@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// latencyBucketBounds are the upper bounds of the -latency-histogram buckets;
+// a last bucket holds everything slower
+var latencyBucketBounds = []time.Duration{time.Millisecond, 5 * time.Millisecond, 20 * time.Millisecond, 100 * time.Millisecond}
+
+// histogramWidth is the length of the longest bar in the table output
+const histogramWidth = 30
+
+// latencyBucket counts the hosts whose ICMP response time falls in a range
+type latencyBucket struct {
+	Label string `json:"bucket"`
+	Count int    `json:"count"`
+}
+
+// latencyHistogram sorts the ICMP response times of the hosts up in the
+// latest scan into buckets. Hosts that did not answer ICMP are left out.
+func latencyHistogram(hosts []HostInfo) []latencyBucket {
+	buckets := make([]latencyBucket, len(latencyBucketBounds)+1)
+	var lower time.Duration
+	for i, bound := range latencyBucketBounds {
+		buckets[i].Label = fmt.Sprintf("%d-%s", lower.Milliseconds(), bound)
+		lower = bound
+	}
+	buckets[len(buckets)-1].Label = lower.String() + "+"
+
+	for _, host := range hosts {
+		if !host.Up || host.Offline || host.ICMPResponseTime <= 0 {
+			continue
+		}
+		i := 0
+		for i < len(latencyBucketBounds) && host.ICMPResponseTime >= latencyBucketBounds[i] {
+			i++
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// writeLatencyHistogram draws the buckets as bars scaled to the largest count
+func writeLatencyHistogram(w io.Writer, buckets []latencyBucket) {
+	most := 0
+	for _, bucket := range buckets {
+		most = max(most, bucket.Count)
+	}
+	if most == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "ICMP response times:")
+	for _, bucket := range buckets {
+		bar := strings.Repeat("#", (bucket.Count*histogramWidth+most-1)/most)
+		fmt.Fprintf(w, "  %-10s %-*s %d\n", bucket.Label, histogramWidth, bar, bucket.Count)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Address Mask messages (RFC 950) are deprecated, so x/net does not name them
+const (
+	icmpTypeAddressMask      ipv4.ICMPType = 17
+	icmpTypeAddressMaskReply ipv4.ICMPType = 18
+)
+
+// icmpQuery is an ICMP request type that hosts dropping echo may still answer
+type icmpQuery struct {
+	name      string
+	request   ipv4.ICMPType
+	reply     ipv4.ICMPType
+	bodyAfter int // Bytes of request body after the identifier and sequence number
+}
+
+// icmpFallbackQueries are tried in order when a host ignores echo requests
+var icmpFallbackQueries = []icmpQuery{
+	{name: "timestamp", request: ipv4.ICMPTypeTimestamp, reply: ipv4.ICMPTypeTimestampReply, bodyAfter: 12},
+	{name: "address mask", request: icmpTypeAddressMask, reply: icmpTypeAddressMaskReply, bodyAfter: 4},
+}
+
+// queryFallback sends the ICMP timestamp and address mask requests in turn and
+// returns the round-trip time and name of the first one answered
+func (s *Scanner) queryFallback(ctx context.Context, conn net.PacketConn, dst *net.IPAddr, id int) (time.Duration, string, bool) {
+	for _, query := range icmpFallbackQueries {
+		if ctx.Err() != nil {
+			break
+		}
+		if rtt, ok := s.query(ctx, conn, dst, id, query); ok {
+			s.logger.Debug("icmp fallback reply", "ip", dst.String(), "type", query.name, "rtt", rtt)
+			return rtt, query.name, true
+		}
+	}
+	return 0, "", false
+}
+
+// query sends one ICMP query request and waits up to s.PingTimeout for its reply
+func (s *Scanner) query(ctx context.Context, conn net.PacketConn, dst *net.IPAddr, id int, query icmpQuery) (time.Duration, bool) {
+	seq := int(s.echoSeq.Add(1) & 0xffff)
+
+	// Both bodies start with the identifier and sequence number; the rest
+	// (timestamps or the mask) is left zero for the host to fill in
+	body := make([]byte, 4+query.bodyAfter)
+	binary.BigEndian.PutUint16(body[0:], uint16(id))
+	binary.BigEndian.PutUint16(body[2:], uint16(seq))
+
+	message := &icmp.Message{Type: query.request, Body: &icmp.RawBody{Data: body}}
+	data, err := message.Marshal(nil)
+	if err != nil {
+		return 0, false
+	}
+
+	deadline := time.Now().Add(s.PingTimeout)
+	start := time.Now()
+	if _, err := conn.WriteTo(data, dst); err != nil {
+		s.logger.Warn("icmp send failed", "ip", dst.String(), "type", query.name, "error", err)
+		return 0, false
+	}
+	s.probes.icmp.Add(1)
+	s.probes.bytes.Add(uint64(ipv4HeaderSize + len(data)))
+
+	reply := make([]byte, 1500)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		conn.SetReadDeadline(minTime(time.Now().Add(100*time.Millisecond), deadline))
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			continue
+		}
+
+		peerIP, ok := peer.(*net.IPAddr)
+		if !ok || !peerIP.IP.Equal(dst.IP) {
+			continue
+		}
+
+		msg, err := icmp.ParseMessage(1, reply[:n])
+		if err != nil || msg.Type != query.reply {
+			continue
+		}
+		raw, ok := msg.Body.(*icmp.RawBody)
+		if !ok || len(raw.Data) < 4 {
+			continue
+		}
+		if int(binary.BigEndian.Uint16(raw.Data[0:])) == id && int(binary.BigEndian.Uint16(raw.Data[2:])) == seq {
+			return time.Since(start), true
+		}
+	}
+
+	return 0, false
+}
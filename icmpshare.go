@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// sharedPinger opens one ICMP socket per address family for a whole scan and
+// hands each host a view of it. Raw sockets receive every ICMP packet, so a
+// socket per host makes the kernel copy each reply to all of them; with one
+// socket a single reader dispatches replies by sequence number instead.
+type sharedPinger struct {
+	base pinger
+
+	mu    sync.Mutex
+	muxes map[string]*icmpMux // By icmpFamily.network
+}
+
+// newSharedPinger shares the sockets opened by base
+func newSharedPinger(base pinger) *sharedPinger {
+	return &sharedPinger{base: base, muxes: make(map[string]*icmpMux)}
+}
+
+// listen returns a connection on the family's shared socket, opening the
+// socket on first use. A failure to open it is not remembered, so a later
+// host tries again.
+func (p *sharedPinger) listen(family icmpFamily) (net.PacketConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mux, ok := p.muxes[family.network]
+	if !ok {
+		conn, err := p.base.listen(family)
+		if err != nil {
+			return nil, err
+		}
+		mux = newICMPMux(conn)
+		p.muxes[family.network] = mux
+	}
+	return mux.open(), nil
+}
+
+// close closes the shared sockets
+func (p *sharedPinger) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, mux := range p.muxes {
+		mux.conn.Close()
+	}
+	p.muxes = nil
+}
+
+// icmpPacket is a message read from a shared socket
+type icmpPacket struct {
+	data []byte
+	peer net.Addr
+}
+
+// icmpMux reads a shared ICMP socket and routes each message to the
+// connection that sent the request with the same sequence number. Echo,
+// timestamp and address mask messages all carry the identifier and sequence
+// number in bytes 4 to 8.
+type icmpMux struct {
+	conn net.PacketConn
+
+	mu      sync.Mutex
+	waiters map[uint16]*sharedICMPConn
+}
+
+func newICMPMux(conn net.PacketConn) *icmpMux {
+	m := &icmpMux{conn: conn, waiters: make(map[uint16]*sharedICMPConn)}
+	go m.dispatch()
+	return m
+}
+
+// dispatch delivers incoming messages until the socket is closed. Messages
+// nobody waits for, including replies to other programs' pings, are dropped.
+func (m *icmpMux) dispatch() {
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := m.conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return
+		}
+		if n < 8 {
+			continue
+		}
+
+		seq := binary.BigEndian.Uint16(buf[6:8])
+		m.mu.Lock()
+		c := m.waiters[seq]
+		m.mu.Unlock()
+		if c == nil {
+			continue
+		}
+
+		packet := icmpPacket{data: append([]byte(nil), buf[:n]...), peer: peer}
+		select {
+		case c.packets <- packet:
+		default: // The host is not reading; drop rather than stall everyone
+		}
+	}
+}
+
+// open returns a new connection for one host
+func (m *icmpMux) open() *sharedICMPConn {
+	return &sharedICMPConn{mux: m, packets: make(chan icmpPacket, 16)}
+}
+
+// sharedICMPConn is one host's view of a shared ICMP socket. It receives the
+// replies to the requests it sent. Only the read deadline is kept per host;
+// writes go straight to the shared socket.
+type sharedICMPConn struct {
+	mux     *icmpMux
+	packets chan icmpPacket
+
+	mu       sync.Mutex
+	seqs     []uint16
+	deadline time.Time
+}
+
+// WriteTo sends an ICMP request and registers its sequence number for replies
+func (c *sharedICMPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if len(b) >= 8 {
+		seq := binary.BigEndian.Uint16(b[6:8])
+		c.mu.Lock()
+		c.seqs = append(c.seqs, seq)
+		c.mu.Unlock()
+
+		c.mux.mu.Lock()
+		c.mux.waiters[seq] = c
+		c.mux.mu.Unlock()
+	}
+	return c.mux.conn.WriteTo(b, addr)
+}
+
+// ReadFrom waits for the next reply until the read deadline
+func (c *sharedICMPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case packet := <-c.packets:
+		return copy(b, packet.data), packet.peer, nil
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+// Close stops routing replies to this connection; the shared socket stays open
+func (c *sharedICMPConn) Close() error {
+	c.mu.Lock()
+	seqs := c.seqs
+	c.seqs = nil
+	c.mu.Unlock()
+
+	c.mux.mu.Lock()
+	for _, seq := range seqs {
+		if c.mux.waiters[seq] == c {
+			delete(c.mux.waiters, seq)
+		}
+	}
+	c.mux.mu.Unlock()
+	return nil
+}
+
+func (c *sharedICMPConn) LocalAddr() net.Addr { return c.mux.conn.LocalAddr() }
+
+func (c *sharedICMPConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *sharedICMPConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *sharedICMPConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"neti/macaddr"
+)
+
+// InterfaceInfo describes a local network interface and the subnets attached to it
+type InterfaceInfo struct {
+	Name          string   `json:"name"`
+	MAC           string   `json:"mac,omitempty"`
+	Addresses     []string `json:"addresses"`
+	SuggestedScan string   `json:"suggested_scan,omitempty"`
+}
+
+// listInterfaces returns every up interface with its CIDRs and a suggested scan target
+func listInterfaces() ([]InterfaceInfo, error) {
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []InterfaceInfo
+	index := make(map[string]int)
+	for _, addr := range addrs {
+		i, ok := index[addr.Interface]
+		if !ok {
+			i = len(infos)
+			index[addr.Interface] = i
+			infos = append(infos, InterfaceInfo{Name: addr.Interface, MAC: addr.MAC})
+		}
+
+		info := &infos[i]
+		info.Addresses = append(info.Addresses, addr.Net.String())
+		if info.SuggestedScan == "" {
+			info.SuggestedScan = suggestScanTarget(addr.Net)
+		}
+	}
+
+	return infos, nil
+}
+
+// suggestScanTarget returns the CIDR most likely worth scanning for an interface address.
+// Only IPv4 networks are suggested; networks wider than a /16 are narrowed to the
+// address's own /24 to keep the scan practical.
+func suggestScanTarget(ipnet *net.IPNet) string {
+	return scanTargetFor(ipnet.IP, ipnet)
+}
+
+// scanTargetFor applies the suggestScanTarget rules to ip inside the network ipnet
+func scanTargetFor(ip net.IP, ipnet *net.IPNet) string {
+	ip = ip.To4()
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return ""
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones >= 31 {
+		return ""
+	}
+	if ones < 16 {
+		ones = 24
+	}
+
+	mask := net.CIDRMask(ones, 32)
+	return fmt.Sprintf("%s/%d", ip.Mask(mask), ones)
+}
+
+// localContext returns the scanning host's own address, interface and
+// gateway for a scan of groups. Each group is reached from its Source, else
+// from source, else from the address the route to its first target leaves
+// from. When the groups are reached from different addresses only what they
+// share is kept, so a scan spanning several links does not claim one of
+// them. Nil when none of it is known.
+func localContext(groups []subnetTargets, source net.IP) *SelfContext {
+	var gateway string
+	if gw, err := macaddr.DefaultGateway(); err == nil {
+		gateway = gw.String()
+	}
+	addrs, _ := macaddr.LocalAddresses()
+
+	var self *SelfContext
+	for _, group := range groups {
+		from := group.Source
+		if from == nil {
+			from = source
+		}
+		ctx := groupContext(group, from, addrs)
+		ctx.Gateway = gateway
+		if self == nil {
+			self = &ctx
+			continue
+		}
+		if self.IP != ctx.IP {
+			self.IP, self.MAC = "", ""
+		}
+		if self.MAC != ctx.MAC {
+			self.MAC = ""
+		}
+		if self.Interface != ctx.Interface {
+			self.Interface = ""
+		}
+		if self.Subnet != ctx.Subnet {
+			self.Subnet = ""
+		}
+	}
+
+	if self == nil || *self == (SelfContext{}) {
+		return nil
+	}
+	return self
+}
+
+// groupContext finds the local address, MAC, interface and subnet a group
+// of targets is scanned from
+func groupContext(group subnetTargets, source net.IP, addrs []macaddr.LocalAddress) SelfContext {
+	var self SelfContext
+	ip := source
+	if ip == nil && len(group.IPs) > 0 {
+		// Dialing UDP only picks the route; nothing is sent
+		if conn, err := net.Dial("udp", net.JoinHostPort(group.IPs[0], "9")); err == nil {
+			ip = conn.LocalAddr().(*net.UDPAddr).IP
+			conn.Close()
+		}
+	}
+	if ip == nil {
+		return self
+	}
+
+	self.IP = ip.String()
+	for _, addr := range addrs {
+		if addr.Net.IP.Equal(ip) {
+			self.MAC = addr.MAC
+			self.Interface = addr.Interface
+			self.Subnet = (&net.IPNet{IP: ip.Mask(addr.Net.Mask), Mask: addr.Net.Mask}).String()
+			break
+		}
+	}
+	return self
+}
+
+// localSourceIP parses addr as an address of a local interface, for binding
+// probes to it
+func localSourceIP(addr string) (net.IP, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source IP %q", addr)
+	}
+
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil {
+		return nil, err
+	}
+	for _, local := range addrs {
+		if local.Net.IP.Equal(ip) {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("source IP %s is not an address of a local interface", addr)
+}
+
+// localSubnetOf returns the scan target for the connected subnet of the local
+// interface whose network contains ip
+func localSubnetOf(ip string) (string, error) {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return "", fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		if !addr.Net.Contains(target) {
+			continue
+		}
+		if cidr := scanTargetFor(target, addr.Net); cidr != "" {
+			return cidr, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s is not on a subnet connected to a local IPv4 interface", ip)
+}
+
+// localScanTargets returns the suggested scan target of every up interface,
+// for a scan started without targets
+func localScanTargets() []string {
+	interfaces, err := listInterfaces()
+	if err != nil {
+		return nil
+	}
+
+	var targets []string
+	for _, iface := range interfaces {
+		if iface.SuggestedScan != "" && !slices.Contains(targets, iface.SuggestedScan) {
+			targets = append(targets, iface.SuggestedScan)
+		}
+	}
+	return targets
+}
+
+// localInterfaceTargets returns the suggested subnet of every up interface
+// along with the interface and its address in the subnet, for
+// -all-interfaces. A subnet reached by several interfaces is scanned from the
+// first.
+func localInterfaceTargets() ([]subnetTargets, error) {
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []subnetTargets
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		cidr := suggestScanTarget(addr.Net)
+		if cidr == "" || seen[cidr] {
+			continue
+		}
+		seen[cidr] = true
+		targets = append(targets, subnetTargets{Subnet: cidr, Interface: addr.Interface, Source: addr.Net.IP})
+	}
+	return targets, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// IPIterator streams the scannable addresses of a network in order without
+// materializing them, so even very large subnets cost constant memory.
+//
+// For IPv4 networks of /30 and wider the network and broadcast addresses are
+// skipped; /31 point-to-point links and /32 hosts yield every address. IPv6
+// has no broadcast, so only the subnet-router anycast address (the first one)
+// is skipped, for /126 and wider.
+type IPIterator struct {
+	next net.IP
+	last net.IP
+	zone string
+	done bool
+}
+
+// NewIPIterator returns an iterator over the addresses of a CIDR or single
+// address. IPv6 targets may carry a zone (e.g. fe80::%eth0/64).
+func NewIPIterator(cidr string) (*IPIterator, error) {
+	return newIPIterator(cidr, false)
+}
+
+// newIPIterator is like NewIPIterator, but with keepEdges set it also yields
+// the network, broadcast and subnet-router anycast addresses
+func newIPIterator(cidr string, keepEdges bool) (*IPIterator, error) {
+	ipNet, zone, err := parseTarget(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSubnet, err)
+	}
+	return newNetIterator(ipNet, zone, keepEdges), nil
+}
+
+// newNetIterator returns an iterator over ipNet, applying the skip rules
+// unless keepEdges is set
+func newNetIterator(ipNet *net.IPNet, zone string, keepEdges bool) *IPIterator {
+	first := ipNet.IP.Mask(ipNet.Mask)
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^ipNet.Mask[i]
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if keepEdges {
+		return &IPIterator{next: first, last: last, zone: zone}
+	}
+	if bits == 32 && ones <= 30 {
+		incrementIP(first)
+		decrementIP(last)
+	} else if bits == 128 && ones <= 126 {
+		incrementIP(first)
+	}
+
+	return &IPIterator{next: first, last: last, zone: zone}
+}
+
+// Next returns the next address, or false once every address was returned
+func (it *IPIterator) Next() (net.IP, bool) {
+	if it.done {
+		return nil, false
+	}
+
+	ip := make(net.IP, len(it.next))
+	copy(ip, it.next)
+	if it.next.Equal(it.last) || !incrementIP(it.next) {
+		it.done = true
+	}
+	return ip, true
+}
+
+// Len returns how many addresses the iterator yields from its current position
+func (it *IPIterator) Len() *big.Int {
+	if it.done {
+		return new(big.Int)
+	}
+	n := new(big.Int).Sub(new(big.Int).SetBytes(it.last), new(big.Int).SetBytes(it.next))
+	return n.Add(n, big.NewInt(1))
+}
+
+// At returns the address offset places after the next one, without advancing.
+// The offset must be less than Len.
+func (it *IPIterator) At(offset *big.Int) net.IP {
+	n := new(big.Int).Add(new(big.Int).SetBytes(it.next), offset)
+	return n.FillBytes(make(net.IP, len(it.next)))
+}
+
+// Zone returns the IPv6 zone of the iterated network, if any
+func (it *IPIterator) Zone() string {
+	return it.zone
+}
+
+// decrementIP decrements an IP address by one
+func decrementIP(ip net.IP) {
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]--
+		if ip[j] != 0xff {
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+// iterate collects every address the iterator over cidr yields
+func iterate(t *testing.T, cidr string, keepEdges bool) []string {
+	t.Helper()
+	it, err := newIPIterator(cidr, keepEdges)
+	if err != nil {
+		t.Fatalf("newIPIterator(%q) failed: %v", cidr, err)
+	}
+
+	var ips []string
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		ips = append(ips, withZone(ip.String(), it.Zone()))
+	}
+	return ips
+}
+
+func TestIPIterator(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidr      string
+		keepEdges bool
+		want      []string
+	}{
+		{"/32", "192.168.1.7/32", false, []string{"192.168.1.7"}},
+		{"single address", "192.168.1.7", false, []string{"192.168.1.7"}},
+		{"/31 keeps both addresses", "10.0.0.0/31", false, []string{"10.0.0.0", "10.0.0.1"}},
+		{"/30 trims network and broadcast", "10.0.0.0/30", false, []string{"10.0.0.1", "10.0.0.2"}},
+		{"/29 trims network and broadcast", "10.0.0.8/29", false, []string{"10.0.0.9", "10.0.0.10", "10.0.0.11", "10.0.0.12", "10.0.0.13", "10.0.0.14"}},
+		{"host bits are masked", "10.0.0.6/30", false, []string{"10.0.0.5", "10.0.0.6"}},
+		{"IncludeNetworkBroadcast", "10.0.0.0/30", true, []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"IncludeNetworkBroadcast on a /31", "10.0.0.0/31", true, []string{"10.0.0.0", "10.0.0.1"}},
+		{"IPv6 skips the subnet-router anycast", "2001:db8::/126", false, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{"IPv6 /127 keeps both addresses", "2001:db8::/127", false, []string{"2001:db8::", "2001:db8::1"}},
+		{"IPv6 with IncludeNetworkBroadcast", "2001:db8::/126", true, []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{"IPv6 zone is kept", "fe80::/127%eth0", false, []string{"fe80::%eth0", "fe80::1%eth0"}},
+		{"IPv4 top of range", "255.255.255.252/30", false, []string{"255.255.255.253", "255.255.255.254"}},
+		{"IPv4 top of range with edges", "255.255.255.254/31", true, []string{"255.255.255.254", "255.255.255.255"}},
+		{"IPv6 top of range", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/126", false, []string{
+			"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffd",
+			"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe",
+			"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+		}},
+		{"IPv6 last address", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128", false, []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := iterate(t, tt.cidr, tt.keepEdges); !slices.Equal(got, tt.want) {
+				t.Errorf("%s yields %v, want %v", tt.cidr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPIteratorLen(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want int64
+	}{
+		{"10.0.0.0/32", 1},
+		{"10.0.0.0/31", 2},
+		{"10.0.0.0/30", 2},
+		{"10.0.0.0/24", 254},
+		{"2001:db8::/120", 255},
+	}
+
+	for _, tt := range tests {
+		it, err := NewIPIterator(tt.cidr)
+		if err != nil {
+			t.Fatalf("NewIPIterator(%q) failed: %v", tt.cidr, err)
+		}
+		if got := it.Len(); got.Int64() != tt.want {
+			t.Errorf("%s Len() = %s, want %d", tt.cidr, got, tt.want)
+		}
+
+		// Len counts from the current position and reaches zero at the end
+		n := tt.want
+		for _, ok := it.Next(); ok; _, ok = it.Next() {
+			n--
+			if got := it.Len(); got.Int64() != n {
+				t.Fatalf("%s Len() = %s after advancing, want %d", tt.cidr, got, n)
+			}
+		}
+		if n != 0 {
+			t.Errorf("%s yielded %d addresses too few", tt.cidr, n)
+		}
+	}
+}
+
+func TestNewIPIteratorInvalid(t *testing.T) {
+	for _, cidr := range []string{"", "10.0.0.0/33", "not-an-ip/24", "10.0.0.256"} {
+		if _, err := NewIPIterator(cidr); !errors.Is(err, ErrInvalidSubnet) {
+			t.Errorf("NewIPIterator(%q) error = %v, want ErrInvalidSubnet", cidr, err)
+		}
+	}
+}
+
+func TestIncrementIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		want   string
+		wantOK bool
+	}{
+		{"10.0.0.1", "10.0.0.2", true},
+		{"10.0.0.255", "10.0.1.0", true},
+		{"255.255.255.254", "255.255.255.255", true},
+		{"255.255.255.255", "0.0.0.0", false},
+		{"2001:db8::ffff", "2001:db8::1:0", true},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", true},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "::", false},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4 // Iterators walk 4-byte IPv4 addresses, which wrap on their own
+		}
+		if ok := incrementIP(ip); ok != tt.wantOK || ip.String() != tt.want {
+			t.Errorf("incrementIP(%s) = %s, %v; want %s, %v", tt.ip, ip, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDecrementIP(t *testing.T) {
+	tests := []struct{ ip, want string }{
+		{"10.0.1.0", "10.0.0.255"},
+		{"255.255.255.255", "255.255.255.254"},
+		{"2001:db8::1:0", "2001:db8::ffff"},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		if decrementIP(ip); ip.String() != tt.want {
+			t.Errorf("decrementIP(%s) = %s, want %s", tt.ip, ip, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// hostLabels maps MACs and IPs to the friendly names given in a -labels file
+type hostLabels struct {
+	byMAC map[string]string // By normalizeMAC form
+	byIP  map[string]string
+}
+
+// loadLabels reads a labels file: either a JSON object of MAC or IP to label,
+// or CSV lines of "mac-or-ip,label". A CSV header line is skipped, as are
+// blank lines and lines starting with '#'.
+func loadLabels(path string) (*hostLabels, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+
+	var entries map[string]string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		entries, err = readLabelsCSV(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse labels %s: %w", path, err)
+	}
+
+	labels, err := newHostLabels(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse labels %s: %w", path, err)
+	}
+	return labels, nil
+}
+
+// readLabelsCSV reads the "mac-or-ip,label" lines of a CSV labels file
+func readLabelsCSV(data []byte) (map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	entries := make(map[string]string)
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && labelKind(record[0]) == "" {
+			continue // Header
+		}
+		entries[record[0]] = record[1]
+	}
+}
+
+// newHostLabels sorts the entries of a labels file into MAC and IP labels
+func newHostLabels(entries map[string]string) (*hostLabels, error) {
+	labels := &hostLabels{byMAC: make(map[string]string), byIP: make(map[string]string)}
+	for key, label := range entries {
+		switch labelKind(key) {
+		case "ip":
+			labels.byIP[net.ParseIP(key).String()] = label
+		case "mac":
+			labels.byMAC[normalizeMAC(key)] = label
+		default:
+			return nil, fmt.Errorf("%q is not a MAC or IP address", key)
+		}
+	}
+	return labels, nil
+}
+
+// labelKind reports whether a labels file key is an "ip" or a "mac", or ""
+// if it is neither
+func labelKind(key string) string {
+	if net.ParseIP(key) != nil {
+		return "ip"
+	}
+	if len(key) <= len("00:00:00:00:00:00") && len(normalizeMAC(key)) == 12 {
+		return "mac"
+	}
+	return ""
+}
+
+// apply labels the hosts that match an entry, by MAC first so a device keeps
+// its label when DHCP gives it another IP, then by IP
+func (l *hostLabels) apply(hosts []HostInfo) {
+	for i := range hosts {
+		host := &hosts[i]
+		if label, ok := l.byMAC[normalizeMAC(host.MAC)]; ok && host.MAC != "" {
+			host.Label = label
+		} else if label, ok := l.byIP[host.IP]; ok {
+			host.Label = label
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"neti/macaddr"
+)
+
+// lldpEtherType identifies LLDP frames (IEEE 802.1AB)
+const lldpEtherType = 0x88cc
+
+// lldpMulticast is the nearest-bridge address LLDP frames are sent to
+var lldpMulticast = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+// LLDP TLV types read from frames
+const (
+	lldpTLVEnd        = 0
+	lldpTLVChassisID  = 1
+	lldpTLVPortID     = 2
+	lldpTLVPortDesc   = 4
+	lldpTLVSystemName = 5
+)
+
+// Chassis and port ID subtypes that carry a MAC address rather than text
+const (
+	lldpChassisSubtypeMAC = 4
+	lldpPortSubtypeMAC    = 3
+)
+
+// lldpNeighbor is what one LLDP frame advertised about the device that sent it
+type lldpNeighbor struct {
+	MAC        string // Source address of the frame
+	ChassisMAC string // Chassis ID, when it is a MAC address
+	SystemName string
+	Port       string // Port description, or the port ID without one
+}
+
+// parseLLDPFrame decodes an Ethernet frame carrying LLDP
+func parseLLDPFrame(frame []byte) (lldpNeighbor, bool) {
+	if len(frame) < 14 || binary.BigEndian.Uint16(frame[12:]) != lldpEtherType {
+		return lldpNeighbor{}, false
+	}
+
+	neighbor := lldpNeighbor{MAC: formatHardwareAddr(frame[6:12])}
+	var portID, portDesc string
+	for data := frame[14:]; len(data) >= 2; {
+		header := binary.BigEndian.Uint16(data)
+		typ, length := int(header>>9), int(header&0x1ff)
+		if len(data) < 2+length {
+			break
+		}
+		value := data[2 : 2+length]
+		data = data[2+length:]
+
+		switch typ {
+		case lldpTLVEnd:
+			data = nil
+		case lldpTLVChassisID:
+			if length == 7 && value[0] == lldpChassisSubtypeMAC {
+				neighbor.ChassisMAC = formatHardwareAddr(value[1:])
+			}
+		case lldpTLVPortID:
+			if length == 7 && value[0] == lldpPortSubtypeMAC {
+				portID = formatHardwareAddr(value[1:])
+			} else if length > 1 {
+				portID = string(value[1:])
+			}
+		case lldpTLVPortDesc:
+			portDesc = string(value)
+		case lldpTLVSystemName:
+			neighbor.SystemName = string(value)
+		}
+	}
+	neighbor.Port = lldpText(portDesc, portID)
+
+	return neighbor, neighbor.SystemName != "" || neighbor.Port != ""
+}
+
+// lldpText returns the first non-empty value, trimmed of the padding some
+// switches add
+func lldpText(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(strings.TrimRight(v, "\x00")); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// formatHardwareAddr formats a MAC address the way HostInfo stores it
+func formatHardwareAddr(b []byte) string {
+	return strings.ToUpper(net.HardwareAddr(b).String())
+}
+
+// lldpCollector gathers LLDP neighbors in the background while a scan runs
+type lldpCollector struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	neighbors map[string]lldpNeighbor // By source and chassis MAC
+	err       error
+}
+
+// startLLDP listens for LLDP frames on iface (every interface when empty) for
+// the given window
+func startLLDP(iface string, window time.Duration) *lldpCollector {
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	c := &lldpCollector{cancel: cancel, done: make(chan struct{}), neighbors: make(map[string]lldpNeighbor)}
+
+	go func() {
+		defer close(c.done)
+		err := captureLLDP(ctx, iface, c.add)
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+	}()
+	return c
+}
+
+func (c *lldpCollector) add(frame []byte) {
+	neighbor, ok := parseLLDPFrame(frame)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.neighbors[neighbor.MAC] = neighbor
+	if neighbor.ChassisMAC != "" {
+		c.neighbors[neighbor.ChassisMAC] = neighbor
+	}
+}
+
+// wait blocks until the listening window ends and returns the neighbors seen
+func (c *lldpCollector) wait() (map[string]lldpNeighbor, error) {
+	<-c.done
+	c.cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.neighbors, c.err
+}
+
+// annotateLLDP fills in the system name and port advertised by each host's MAC
+func annotateLLDP(result *ScanResult, neighbors map[string]lldpNeighbor) int {
+	matched := 0
+	for i := range result.ReachableHosts {
+		host := &result.ReachableHosts[i]
+		neighbor, ok := neighbors[strings.ToUpper(host.MAC)]
+		if host.MAC == "" || !ok {
+			continue
+		}
+		host.LLDPSystemName = neighbor.SystemName
+		host.LLDPPort = neighbor.Port
+		matched++
+	}
+	return matched
+}
+
+// lldpInterface returns the local interface on the same network as the first
+// target, or "" to listen on every interface
+func lldpInterface(ips []string) string {
+	if len(ips) == 0 {
+		return ""
+	}
+	ip := net.ParseIP(ips[0])
+
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil || ip == nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if addr.Net.Contains(ip) {
+			return addr.Interface
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// captureLLDP passes every LLDP frame received on iface (every interface when
+// empty) to frame until ctx is done
+func captureLLDP(ctx context.Context, iface string, frame func([]byte)) error {
+	proto := int(htons(lldpEtherType))
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, proto)
+	if err != nil {
+		return fmt.Errorf("opening packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	if iface != "" {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			return err
+		}
+		if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(lldpEtherType), Ifindex: ifi.Index}); err != nil {
+			return fmt.Errorf("binding to %s: %w", iface, err)
+		}
+
+		// The nearest-bridge address is not accepted by every NIC by default
+		mreq := &unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_MULTICAST, Alen: 6}
+		copy(mreq.Address[:], lldpMulticast)
+		unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq)
+	}
+
+	// Wake up regularly to notice when ctx is done
+	tv := unix.NsecToTimeval(int64(100 * 1e6))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1518)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			continue
+		}
+		frame(buf[:n])
+	}
+	return nil
+}
+
+// htons converts a 16-bit value to network byte order
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
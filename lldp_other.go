@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// captureLLDP is not implemented on this platform
+func captureLLDP(ctx context.Context, iface string, frame func([]byte)) error {
+	return fmt.Errorf("listening for LLDP frames is not supported on %s", runtime.GOOS)
+}
@@ -2,6 +2,15 @@
 
 package macaddr
 
+import (
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/net/route"
+	"golang.org/x/sys/unix"
+)
+
 // macOS implementation - will only be compiled on macOS/Darwin systems
 func init() {
 	// Override the default ARP table loader with the macOS-specific one
@@ -18,10 +27,102 @@ func loadDarwinARPTable(r *Resolver) {
 		return
 	}
 
-	// macOS implementation would use syscalls to:
-	// - call sysctl with CTL_NET, PF_ROUTE, 0, AF_INET, NET_RT_FLAGS, RTF_LLINFO
-	// - parse the sockaddr structures to extract IP and MAC addresses
+	// Equivalent to the net.route.0.inet.flags.llinfo sysctl:
+	// {CTL_NET, PF_ROUTE, 0, AF_INET, NET_RT_FLAGS, RTF_LLINFO}
+	buf, err := route.FetchRIB(unix.AF_INET, unix.NET_RT_FLAGS, unix.RTF_LLINFO)
+	if err != nil {
+		return
+	}
+
+	for ip, mac := range parseDarwinRouteDump(buf) {
+		r.cache[ip] = mac
+	}
 
-	// For now, just mark as loaded - placeholder for future implementation
 	r.arpLoaded = true
 }
+
+// parseDarwinRouteDump walks a routing table dump made of rt_msghdr records,
+// each followed by its sockaddrs, and returns the IP to MAC pairs it finds.
+func parseDarwinRouteDump(buf []byte) map[string]string {
+	entries := make(map[string]string)
+
+	for len(buf) >= unix.SizeofRtMsghdr {
+		hdr := (*unix.RtMsghdr)(unsafe.Pointer(&buf[0]))
+		msgLen := int(hdr.Msglen)
+		if msgLen < unix.SizeofRtMsghdr || msgLen > len(buf) {
+			break
+		}
+
+		if hdr.Version == unix.RTM_VERSION {
+			ip, mac := parseDarwinRouteAddrs(buf[unix.SizeofRtMsghdr:msgLen], hdr.Addrs)
+			if ip != "" && mac != "" {
+				entries[ip] = mac
+			}
+		}
+
+		buf = buf[msgLen:]
+	}
+
+	return entries
+}
+
+// parseDarwinRouteAddrs extracts the destination IP (RTA_DST) and the link-layer
+// gateway (RTA_GATEWAY) from the sockaddrs that follow an rt_msghdr.
+func parseDarwinRouteAddrs(b []byte, addrs int32) (string, string) {
+	var ip, mac string
+
+	for i := 0; i < unix.RTAX_MAX && len(b) > 0; i++ {
+		if addrs&(1<<i) == 0 {
+			continue
+		}
+
+		// Every sockaddr starts with its length and family
+		saLen := int(b[0])
+		if len(b) < 2 || saLen > len(b) {
+			break
+		}
+
+		switch {
+		case i == unix.RTAX_DST && b[1] == unix.AF_INET && saLen >= unix.SizeofSockaddrInet4:
+			sa := (*unix.RawSockaddrInet4)(unsafe.Pointer(&b[0]))
+			ip = net.IP(sa.Addr[:]).String()
+		case i == unix.RTAX_GATEWAY && b[1] == unix.AF_LINK && saLen >= 8:
+			mac = parseSockaddrDatalink(b[:saLen])
+		}
+
+		next := roundupSockaddr(saLen)
+		if next > len(b) {
+			break
+		}
+		b = b[next:]
+	}
+
+	return ip, mac
+}
+
+// parseSockaddrDatalink returns the hardware address stored in a sockaddr_dl.
+// The address follows the interface name inside sdl_data.
+func parseSockaddrDatalink(b []byte) string {
+	nameLen := int(b[5])
+	addrLen := int(b[6])
+	start := 8 + nameLen
+
+	if addrLen != 6 || start+addrLen > len(b) {
+		return ""
+	}
+
+	hw := b[start : start+addrLen]
+	mac := fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", hw[0], hw[1], hw[2], hw[3], hw[4], hw[5])
+	if mac == "00:00:00:00:00:00" {
+		return ""
+	}
+	return mac
+}
+
+// roundupSockaddr mirrors the kernel's ROUNDUP macro, aligning sockaddrs to 4 bytes.
+func roundupSockaddr(l int) int {
+	if l == 0 {
+		return 4
+	}
+	return (l + 3) &^ 3
+}
@@ -0,0 +1,149 @@
+//go:build darwin
+
+package macaddr
+
+import (
+	"maps"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Sockaddrs laid out as they follow the rt_msghdr of an RTF_LLINFO entry in
+// the NET_RT_FLAGS dump behind `sysctl net.route.0.inet.flags.llinfo`
+var (
+	// sockaddr_in for 192.168.1.1
+	sinRouter = []byte{0x10, unix.AF_INET, 0, 0, 192, 168, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	// sockaddr_in for 192.168.1.20
+	sinLaptop = []byte{0x10, unix.AF_INET, 0, 0, 192, 168, 1, 20, 0, 0, 0, 0, 0, 0, 0, 0}
+	// sockaddr_dl on en0 (index 4, IFT_ETHER) holding a0:ce:c8:12:34:56
+	sdlRouter = []byte{0x14, unix.AF_LINK, 4, 0, 6, 3, 6, 0, 'e', 'n', '0', 0xa0, 0xce, 0xc8, 0x12, 0x34, 0x56, 0, 0, 0}
+	// sockaddr_dl on en0 holding 3c:22:fb:aa:bb:cc
+	sdlLaptop = []byte{0x14, unix.AF_LINK, 4, 0, 6, 3, 6, 0, 'e', 'n', '0', 0x3c, 0x22, 0xfb, 0xaa, 0xbb, 0xcc, 0, 0, 0}
+	// sockaddr_dl of an incomplete entry: the kernel is still resolving the
+	// neighbor, so sdl_alen is 0
+	sdlIncomplete = []byte{0x14, unix.AF_LINK, 4, 0, 6, 3, 0, 0, 'e', 'n', '0', 0, 0, 0, 0, 0, 0, 0, 0, 0}
+)
+
+// rtMessage builds one routing message: an rt_msghdr carrying addrs and
+// the given sockaddrs, each padded as roundupSockaddr expects
+func rtMessage(version uint8, addrs int32, sockaddrs ...[]byte) []byte {
+	var body []byte
+	for _, sa := range sockaddrs {
+		padded := make([]byte, roundupSockaddr(len(sa)))
+		copy(padded, sa)
+		body = append(body, padded...)
+	}
+
+	hdr := unix.RtMsghdr{
+		Msglen:  uint16(unix.SizeofRtMsghdr + len(body)),
+		Version: version,
+		Type:    unix.RTM_GET,
+		Flags:   unix.RTF_UP | unix.RTF_HOST | unix.RTF_LLINFO,
+		Addrs:   addrs,
+	}
+	msg := unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), unix.SizeofRtMsghdr)
+	return append(append([]byte(nil), msg...), body...)
+}
+
+func TestParseDarwinRouteDump(t *testing.T) {
+	const dstGateway = unix.RTA_DST | unix.RTA_GATEWAY
+
+	router := rtMessage(unix.RTM_VERSION, dstGateway, sinRouter, sdlRouter)
+	laptop := rtMessage(unix.RTM_VERSION, dstGateway, sinLaptop, sdlLaptop)
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want map[string]string
+	}{
+		{
+			name: "two entries",
+			buf:  append(append([]byte(nil), router...), laptop...),
+			want: map[string]string{"192.168.1.1": "A0:CE:C8:12:34:56", "192.168.1.20": "3C:22:FB:AA:BB:CC"},
+		},
+		{
+			name: "zero-length sdl_alen",
+			buf:  append(rtMessage(unix.RTM_VERSION, dstGateway, sinLaptop, sdlIncomplete), router...),
+			want: map[string]string{"192.168.1.1": "A0:CE:C8:12:34:56"},
+		},
+		{
+			name: "truncated sockaddr",
+			// The message claims the whole sockaddr_dl but the buffer ends inside it
+			buf: func() []byte {
+				msg := rtMessage(unix.RTM_VERSION, dstGateway, sinLaptop, sdlLaptop)
+				msg = msg[:len(msg)-12]
+				msg[0], msg[1] = byte(len(msg)), byte(len(msg)>>8)
+				return append(append([]byte(nil), router...), msg...)
+			}(),
+			want: map[string]string{"192.168.1.1": "A0:CE:C8:12:34:56"},
+		},
+		{
+			name: "truncated message",
+			buf:  append(append([]byte(nil), router...), laptop[:unix.SizeofRtMsghdr+4]...),
+			want: map[string]string{"192.168.1.1": "A0:CE:C8:12:34:56"},
+		},
+		{
+			name: "other message version",
+			buf:  append(rtMessage(unix.RTM_VERSION+1, dstGateway, sinLaptop, sdlLaptop), router...),
+			want: map[string]string{"192.168.1.1": "A0:CE:C8:12:34:56"},
+		},
+		{
+			name: "no gateway sockaddr",
+			buf:  rtMessage(unix.RTM_VERSION, unix.RTA_DST, sinLaptop),
+			want: map[string]string{},
+		},
+		{
+			name: "empty",
+			buf:  nil,
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDarwinRouteDump(tt.buf); !maps.Equal(got, tt.want) {
+				t.Errorf("parseDarwinRouteDump() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSockaddrDatalink(t *testing.T) {
+	tests := []struct {
+		name string
+		sdl  []byte
+		want string
+	}{
+		{"ethernet", sdlRouter, "A0:CE:C8:12:34:56"},
+		{"zero-length sdl_alen", sdlIncomplete, ""},
+		{"address past the end", sdlRouter[:14], ""},
+		{"all zero address", []byte{0x14, unix.AF_LINK, 4, 0, 6, 3, 6, 0, 'e', 'n', '0', 0, 0, 0, 0, 0, 0, 0, 0, 0}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSockaddrDatalink(tt.sdl); got != tt.want {
+				t.Errorf("parseSockaddrDatalink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundupSockaddr(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, 4},
+		{1, 4},
+		{4, 4},
+		{16, 16},
+		{17, 20},
+		{20, 20},
+	}
+
+	for _, tt := range tests {
+		if got := roundupSockaddr(tt.in); got != tt.want {
+			t.Errorf("roundupSockaddr(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,12 @@
 package macaddr
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"net"
+	"os/exec"
+	"strings"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -30,7 +35,9 @@ type MIB_IPNETROW struct {
 	Type        uint32
 }
 
-// loadWindowsARPTable loads all entries from the Windows ARP table into the cache
+// loadWindowsARPTable loads all entries from the Windows ARP table into the cache.
+// It falls back to the output of "arp -a" when GetIpNetTable returns nothing,
+// as it may on restricted systems or if the row layout ever changes.
 func loadWindowsARPTable(r *Resolver) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -40,16 +47,31 @@ func loadWindowsARPTable(r *Resolver) {
 		return
 	}
 
+	entries := readIPNetTable()
+	if len(entries) == 0 {
+		entries = readARPCommand()
+	}
+	for ip, mac := range entries {
+		r.cache[ip] = mac
+	}
+
+	r.arpLoaded = true
+}
+
+// readIPNetTable returns the IP to MAC pairs of the table GetIpNetTable reports
+func readIPNetTable() map[string]string {
+	entries := make(map[string]string)
+
 	// Load required DLL and functions
 	iphlpapi, err := windows.LoadDLL("iphlpapi.dll")
 	if err != nil {
-		return
+		return entries
 	}
 	defer iphlpapi.Release()
 
 	getIpNetTableProc, err := iphlpapi.FindProc("GetIpNetTable")
 	if err != nil {
-		return
+		return entries
 	}
 
 	// First call to get required size
@@ -57,7 +79,7 @@ func loadWindowsARPTable(r *Resolver) {
 	ret, _, _ := getIpNetTableProc.Call(0, uintptr(unsafe.Pointer(&size)), 1)
 
 	if ret != ERROR_INSUFFICIENT_BUFFER {
-		return
+		return entries
 	}
 
 	// Allocate buffer
@@ -65,7 +87,7 @@ func loadWindowsARPTable(r *Resolver) {
 	ret, _, _ = getIpNetTableProc.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
 
 	if ret != NO_ERROR {
-		return
+		return entries
 	}
 
 	// Parse table entries
@@ -101,10 +123,48 @@ func loadWindowsARPTable(r *Resolver) {
 
 			// Only store valid MACs
 			if mac != "00:00:00:00:00:00" {
-				r.cache[ipStr] = mac
+				entries[ipStr] = mac
 			}
 		}
 	}
 
-	r.arpLoaded = true
+	return entries
+}
+
+// readARPCommand returns the IP to MAC pairs listed by "arp -a"
+func readARPCommand() map[string]string {
+	out, err := exec.Command("arp", "-a").Output()
+	if err != nil {
+		return nil
+	}
+	return parseARPOutput(out)
+}
+
+// parseARPOutput parses the tables "arp -a" prints for each interface. Entry
+// lines start with the Internet Address and Physical Address columns, with the
+// MAC written like 00-11-22-33-44-55. The headings are translated on
+// non-English systems, so lines are recognized by their values instead.
+func parseARPOutput(out []byte) map[string]string {
+	entries := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0]).To4()
+		hw, err := net.ParseMAC(fields[1])
+		if ip == nil || err != nil || len(hw) != 6 {
+			continue
+		}
+
+		mac := strings.ToUpper(hw.String())
+		if mac != "00:00:00:00:00:00" {
+			entries[ip.String()] = mac
+		}
+	}
+
+	return entries
 }
@@ -0,0 +1,21 @@
+package macaddr
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// GatewayFinder is a platform-specific function type for finding the default gateway.
+type GatewayFinder func() (net.IP, error)
+
+// defaultGatewayFinder is set in init() by the platform that supports it
+var defaultGatewayFinder GatewayFinder
+
+// DefaultGateway returns the IPv4 address of this host's default gateway.
+func DefaultGateway() (net.IP, error) {
+	if defaultGatewayFinder == nil {
+		return nil, fmt.Errorf("default gateway lookup is not supported on %s", runtime.GOOS)
+	}
+	return defaultGatewayFinder()
+}
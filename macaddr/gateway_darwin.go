@@ -0,0 +1,86 @@
+//go:build darwin
+
+package macaddr
+
+import (
+	"errors"
+	"net"
+	"unsafe"
+
+	"golang.org/x/net/route"
+	"golang.org/x/sys/unix"
+)
+
+// macOS implementation - will only be compiled on macOS/Darwin systems
+func init() {
+	defaultGatewayFinder = findDarwinDefaultGateway
+}
+
+// findDarwinDefaultGateway looks up the default route in a dump of the IPv4
+// routing table (the net.route.0.inet.dump sysctl)
+func findDarwinDefaultGateway() (net.IP, error) {
+	buf, err := route.FetchRIB(unix.AF_INET, unix.NET_RT_DUMP, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(buf) >= unix.SizeofRtMsghdr {
+		hdr := (*unix.RtMsghdr)(unsafe.Pointer(&buf[0]))
+		msgLen := int(hdr.Msglen)
+		if msgLen < unix.SizeofRtMsghdr || msgLen > len(buf) {
+			break
+		}
+
+		if hdr.Version == unix.RTM_VERSION && hdr.Flags&unix.RTF_GATEWAY != 0 {
+			sas := splitDarwinSockaddrs(buf[unix.SizeofRtMsghdr:msgLen], hdr.Addrs)
+			dst, gw := sockaddrInet4(sas[unix.RTAX_DST]), sockaddrInet4(sas[unix.RTAX_GATEWAY])
+			if dst != nil && dst.Equal(net.IPv4zero) && gw != nil {
+				return gw, nil
+			}
+		}
+
+		buf = buf[msgLen:]
+	}
+
+	return nil, errors.New("no default route")
+}
+
+// splitDarwinSockaddrs returns the sockaddrs that follow an rt_msghdr, indexed
+// by their RTAX_* position. Absent addresses are nil.
+func splitDarwinSockaddrs(b []byte, addrs int32) [unix.RTAX_MAX][]byte {
+	var sas [unix.RTAX_MAX][]byte
+
+	for i := 0; i < unix.RTAX_MAX && len(b) > 0; i++ {
+		if addrs&(1<<i) == 0 {
+			continue
+		}
+
+		saLen := int(b[0])
+		if saLen > len(b) {
+			break
+		}
+		sas[i] = b[:saLen]
+
+		next := roundupSockaddr(saLen)
+		if next > len(b) {
+			break
+		}
+		b = b[next:]
+	}
+
+	return sas
+}
+
+// sockaddrInet4 returns the address in a sockaddr_in, or nil for any other
+// family. The kernel may shorten sockaddrs, so a missing tail is zero.
+func sockaddrInet4(sa []byte) net.IP {
+	if len(sa) < 2 || sa[1] != unix.AF_INET {
+		return nil
+	}
+
+	ip := make(net.IP, 4)
+	if len(sa) > 4 {
+		copy(ip, sa[4:min(len(sa), 8)])
+	}
+	return ip
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package macaddr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Linux implementation - will only be compiled on Linux
+func init() {
+	defaultGatewayFinder = findLinuxDefaultGateway
+}
+
+// rtfGateway is the RTF_GATEWAY route flag
+const rtfGateway = 0x2
+
+// procNetRoute is the kernel's IPv4 routing table
+const procNetRoute = "/proc/net/route"
+
+// findLinuxDefaultGateway reads the default route from /proc/net/route
+func findLinuxDefaultGateway() (net.IP, error) {
+	file, err := os.Open(procNetRoute)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseProcNetRoute(file)
+}
+
+// parseProcNetRoute returns the gateway of the default route in the contents
+// of /proc/net/route, where addresses are hex in host (little-endian) byte
+// order. With several default routes the one with the lowest metric wins, as
+// it does in the kernel. It takes a reader so sample tables can be parsed.
+func parseProcNetRoute(r io.Reader) (net.IP, error) {
+	var gateway net.IP
+	var bestMetric uint64
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Skip header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 || fields[1] != "00000000" {
+			continue
+		}
+
+		flags, err := hex.DecodeString(fields[3])
+		if err != nil || len(flags) != 2 || binary.BigEndian.Uint16(flags)&rtfGateway == 0 {
+			continue
+		}
+
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != 4 {
+			continue
+		}
+
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			continue
+		}
+		if gateway == nil || metric < bestMetric {
+			gateway, bestMetric = net.IPv4(gw[3], gw[2], gw[1], gw[0]), metric
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if gateway == nil {
+		return nil, errors.New("no default route")
+	}
+	return gateway, nil
+}
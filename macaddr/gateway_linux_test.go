@@ -0,0 +1,80 @@
+//go:build linux
+
+package macaddr
+
+import (
+	"strings"
+	"testing"
+)
+
+const procNetRouteHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+
+func TestParseProcNetRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  string // "" when no default route should be found
+	}{
+		{
+			name: "default route",
+			table: "eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n",
+			want: "192.168.1.1",
+		},
+		{
+			name: "no default route",
+			table: "eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n" +
+				"docker0\t000011AC\t00000000\t0001\t0\t0\t0\t0000FFFF\t0\t0\t0\n",
+		},
+		{
+			// A default route straight out of an interface, as point-to-point
+			// links and some VPNs install, has no gateway to report
+			name:  "default route without RTF_GATEWAY",
+			table: "tun0\t00000000\t00000000\t0001\t0\t0\t50\t00000000\t0\t0\t0\n",
+		},
+		{
+			name: "lowest metric wins",
+			table: "wlan0\t00000000\t0100000A\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"usb0\t00000000\t012AA8C0\t0003\t0\t0\t700\t00000000\t0\t0\t0\n",
+			want: "192.168.1.1",
+		},
+		{
+			name: "gatewayless default route beside a real one",
+			table: "tun0\t00000000\t00000000\t0001\t0\t0\t0\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n",
+			want: "192.168.1.1",
+		},
+		{
+			name: "malformed rows are skipped",
+			table: "eth0\t00000000\tZZZZZZZZ\t0003\t0\t0\t0\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0101A8C0\tXY\t0\t0\t0\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0101A8C0\t0003\t0\t0\tbad\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t0101A8C0\n" +
+				"eth1\t00000000\t0100000A\t0003\t0\t0\t200\t00000000\t0\t0\t0\n",
+			want: "10.0.0.1",
+		},
+		{
+			name:  "header only",
+			table: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, err := parseProcNetRoute(strings.NewReader(procNetRouteHeader + tt.table))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("parseProcNetRoute() = %s, want no default route", gw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcNetRoute() failed: %v", err)
+			}
+			if gw.String() != tt.want {
+				t.Errorf("parseProcNetRoute() = %s, want %s", gw, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package macaddr
+
+import (
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows implementation - will only be compiled on Windows
+func init() {
+	defaultGatewayFinder = findWindowsDefaultGateway
+}
+
+// MIB_IPFORWARDROW structure for GetBestRoute function
+type MIB_IPFORWARDROW struct {
+	ForwardDest      uint32
+	ForwardMask      uint32
+	ForwardPolicy    uint32
+	ForwardNextHop   uint32
+	ForwardIfIndex   uint32
+	ForwardType      uint32
+	ForwardProto     uint32
+	ForwardAge       uint32
+	ForwardNextHopAS uint32
+	ForwardMetric1   uint32
+	ForwardMetric2   uint32
+	ForwardMetric3   uint32
+	ForwardMetric4   uint32
+	ForwardMetric5   uint32
+}
+
+// findWindowsDefaultGateway asks GetBestRoute for the route to 0.0.0.0, which is
+// the default route
+func findWindowsDefaultGateway() (net.IP, error) {
+	iphlpapi, err := windows.LoadDLL("iphlpapi.dll")
+	if err != nil {
+		return nil, err
+	}
+	defer iphlpapi.Release()
+
+	getBestRouteProc, err := iphlpapi.FindProc("GetBestRoute")
+	if err != nil {
+		return nil, err
+	}
+
+	var row MIB_IPFORWARDROW
+	ret, _, _ := getBestRouteProc.Call(0, 0, uintptr(unsafe.Pointer(&row)))
+	if ret != NO_ERROR {
+		return nil, fmt.Errorf("GetBestRoute failed with error %d", ret)
+	}
+	if row.ForwardNextHop == 0 {
+		return nil, fmt.Errorf("no default route")
+	}
+
+	// Convert the next hop from host byte order to network byte order
+	hop := row.ForwardNextHop
+	return net.IPv4(byte(hop), byte(hop>>8), byte(hop>>16), byte(hop>>24)), nil
+}
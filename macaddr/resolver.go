@@ -1,10 +1,19 @@
 package macaddr
 
 import (
+	"context"
 	"net"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
+)
+
+// Defaults for the ARP request fallback. The kernel needs a moment to learn a
+// MAC after the request, so the table is reloaded only after a short wait.
+const (
+	DefaultARPTimeout = 200 * time.Millisecond
+	DefaultARPRetries = 2
 )
 
 // Resolver handles MAC address resolution for different platforms.
@@ -17,6 +26,14 @@ type Resolver struct {
 	loadARPTableFunc func(*Resolver)
 	// Mutex to protect concurrent access to the cache and arpLoaded flag
 	mutex sync.Mutex
+	// Networks of the local, non-loopback interfaces, listed on first use
+	connected     []*net.IPNet
+	connectedOnce sync.Once
+
+	// How long to wait for the ARP table to learn a MAC after each ARP request
+	ARPTimeout time.Duration
+	// Further ARP requests to send when the first one is not answered in time
+	ARPRetries int
 }
 
 // ARPTableLoader is a platform-specific function type for loading ARP tables.
@@ -32,8 +49,10 @@ var (
 // NewResolver creates a new MAC address resolver.
 func NewResolver() *Resolver {
 	resolver := &Resolver{
-		cache:     make(map[string]string),
-		arpLoaded: false,
+		cache:      make(map[string]string),
+		arpLoaded:  false,
+		ARPTimeout: DefaultARPTimeout,
+		ARPRetries: DefaultARPRetries,
 	}
 
 	// Set the appropriate ARP table loader based on platform
@@ -59,6 +78,13 @@ func NewResolver() *Resolver {
 
 // GetMACAddress gets the MAC address for an IP using platform-specific methods.
 func (r *Resolver) GetMACAddress(ip string) string {
+	return r.GetMACAddressContext(context.Background(), ip)
+}
+
+// GetMACAddressContext is like GetMACAddress but gives up between the ARP table
+// reloads and the ARP request once ctx is done, returning "" unless the MAC was
+// already known.
+func (r *Resolver) GetMACAddressContext(ctx context.Context, ip string) string {
 	// First check the cache for previously resolved MAC addresses
 	if mac := r.getMACFromCache(ip); mac != "" {
 		return mac
@@ -75,6 +101,9 @@ func (r *Resolver) GetMACAddress(ip string) string {
 	}
 
 	// If not a local IP, try platform-specific ARP table lookups
+	if ctx.Err() != nil {
+		return ""
+	}
 	r.ensureARPTableLoaded()
 
 	// Check cache again after platform-specific ARP table load
@@ -83,6 +112,9 @@ func (r *Resolver) GetMACAddress(ip string) string {
 	}
 
 	// Try reloading the ARP table - it might have been updated
+	if ctx.Err() != nil {
+		return ""
+	}
 	r.reloadARPTable()
 
 	// Final cache check
@@ -91,10 +123,67 @@ func (r *Resolver) GetMACAddress(ip string) string {
 		return mac
 	}
 
-	// Fallback: send ARP request and reload ARP table
-	sendARPRequest(ip)
-	r.reloadARPTable()
-	return r.getMACFromCache(ip)
+	// Only neighbors on a connected subnet ever get an ARP entry
+	if !r.onConnectedSubnet(ip) {
+		return ""
+	}
+
+	// Fallback: send ARP requests, giving the kernel time to record each reply
+	// before reloading the ARP table
+	for attempt := 0; attempt <= r.ARPRetries; attempt++ {
+		if ctx.Err() != nil {
+			return ""
+		}
+		sendARPRequest(ctx, ip)
+		if !sleepContext(ctx, r.ARPTimeout) {
+			return ""
+		}
+		r.reloadARPTable()
+		if mac := r.getMACFromCache(ip); mac != "" {
+			return mac
+		}
+	}
+	return ""
+}
+
+// onConnectedSubnet reports whether ip is on the network of a local,
+// non-loopback interface, where it can be reached without a router. The
+// interfaces are listed once per Resolver rather than on every cache miss.
+func (r *Resolver) onConnectedSubnet(ip string) bool {
+	targetIP := net.ParseIP(ip)
+	if targetIP == nil || targetIP.IsLoopback() {
+		return false
+	}
+
+	r.connectedOnce.Do(func() {
+		addrs, err := LocalAddresses()
+		if err != nil {
+			return
+		}
+		for _, addr := range addrs {
+			if !addr.Net.IP.IsLoopback() {
+				r.connected = append(r.connected, addr.Net)
+			}
+		}
+	})
+	for _, network := range r.connected {
+		if network.Contains(targetIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepContext waits for d and reports whether ctx was still active afterwards
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // getMACFromCache checks if an IP address is in the cache.
@@ -108,8 +197,9 @@ func (r *Resolver) getMACFromCache(ip string) string {
 }
 
 // sendARPRequest sends a dummy UDP packet to the target IP to trigger an ARP request.
-func sendARPRequest(ip string) {
-	conn, err := net.Dial("udp", ip+":0")
+func sendARPRequest(ctx context.Context, ip string) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip, "0"))
 	if err == nil {
 		conn.Close()
 	}
@@ -135,6 +225,9 @@ func (r *Resolver) ensureARPTableLoaded() {
 func (r *Resolver) reloadARPTable() {
 	// Reset the flag and reload. The lock inside the loader will handle synchronization.
 	if r.loadARPTableFunc != nil {
+		r.mutex.Lock()
+		r.arpLoaded = false
+		r.mutex.Unlock()
 		r.loadARPTableFunc(r)
 	}
 }
@@ -146,11 +239,34 @@ func (r *Resolver) getMACFromLocalInterfaces(ip string) string {
 		return ""
 	}
 
-	interfaces, err := net.Interfaces()
+	addrs, err := LocalAddresses()
 	if err != nil {
 		return ""
 	}
 
+	for _, addr := range addrs {
+		if addr.Net.IP.Equal(targetIP) {
+			return addr.MAC
+		}
+	}
+	return ""
+}
+
+// LocalAddress is an address assigned to a local network interface that is up.
+type LocalAddress struct {
+	Interface string
+	MAC       string
+	Net       *net.IPNet
+}
+
+// LocalAddresses lists the addresses of all local network interfaces that are up.
+func LocalAddresses() ([]LocalAddress, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var local []LocalAddress
 	for _, iface := range interfaces {
 		if iface.Flags&net.FlagUp == 0 {
 			continue
@@ -161,11 +277,13 @@ func (r *Resolver) getMACFromLocalInterfaces(ip string) string {
 		}
 		for _, addr := range addrs {
 			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.Equal(targetIP) {
-					return strings.ToUpper(iface.HardwareAddr.String())
-				}
+				local = append(local, LocalAddress{
+					Interface: iface.Name,
+					MAC:       strings.ToUpper(iface.HardwareAddr.String()),
+					Net:       ipnet,
+				})
 			}
 		}
 	}
-	return ""
+	return local, nil
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MAC address output styles. MACs are always stored colon-separated and
+// uppercase; the style only applies when results are rendered.
+const (
+	MACFormatColon  = "colon"  // 00:1A:2B:3C:4D:5E
+	MACFormatHyphen = "hyphen" // 00-1A-2B-3C-4D-5E
+	MACFormatCisco  = "cisco"  // 001a.2b3c.4d5e
+	MACFormatBare   = "bare"   // 001A2B3C4D5E
+)
+
+// validateMACFormat checks that the requested MAC style is supported
+func validateMACFormat(style string) error {
+	switch style {
+	case MACFormatColon, MACFormatHyphen, MACFormatCisco, MACFormatBare:
+		return nil
+	}
+	return fmt.Errorf("unsupported MAC format %q (use %s, %s, %s or %s)", style, MACFormatColon, MACFormatHyphen, MACFormatCisco, MACFormatBare)
+}
+
+// formatMAC renders mac in the given style. Anything that is not a 48-bit MAC
+// is returned unchanged.
+func formatMAC(mac, style string) string {
+	hex := normalizeMAC(mac)
+	if len(hex) != 12 {
+		return mac
+	}
+
+	switch style {
+	case MACFormatColon:
+		return joinOctets(hex, ":")
+	case MACFormatHyphen:
+		return joinOctets(hex, "-")
+	case MACFormatCisco:
+		hex = strings.ToLower(hex)
+		return hex[0:4] + "." + hex[4:8] + "." + hex[8:12]
+	case MACFormatBare:
+		return hex
+	}
+	return mac
+}
+
+// joinOctets separates each pair of hex digits with sep
+func joinOctets(hex, sep string) string {
+	octets := make([]string, 0, len(hex)/2)
+	for i := 0; i+1 < len(hex); i += 2 {
+		octets = append(octets, hex[i:i+2])
+	}
+	return strings.Join(octets, sep)
+}
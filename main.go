@@ -1,48 +1,953 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"neti/macaddr"
 )
 
 func main() {
 	ui := NewUI()
+
+	// Subcommands are dispatched before the scan flags are parsed
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "interfaces":
+			runInterfaces(ui, os.Args[2:])
+			return
+		case "vendor":
+			runVendor(ui, os.Args[2:])
+			return
+		case "render":
+			runRender(ui, os.Args[2:])
+			return
+		case "ping":
+			runPing(ui, os.Args[2:])
+			return
+		}
+	}
+
 	scanner := NewScanner()
 
 	var subnet string
 	var useTCP bool
 	var useUDP bool
-	flag.StringVar(&subnet, "subnet", "", "CIDR subnet to scan (e.g. 192.168.1.0/24)")
+	var portSpec, serviceSpec, udpPortSpec, excludeSpec string
+	var portsFilePath string
+	var progressFD int
+	var sample int
+	var seed int64
+	var vendor string
+	var groupBy string
+	var sortOrder string
+	var baselinePath string
+	var labelsPath string
+	var continueOnError bool
+	var statePath string
+	var onlyNew bool
+	var ouiURL string
+	var localSubnetIP string
+	var allInterfaces bool
+	var interactive bool
+	var timeout, pingTimeout, tcpTimeout, udpTimeout time.Duration
+	var portTimeouts string
+	var deadline, watch time.Duration
+	var minRTT, maxRTT time.Duration
+	var outputDir string
+	var dohURL string
+	var sourceIP string
+	var socks5 string
+	var failOnPermission bool
+	var blockPublicDNS bool
+	var subnetParallelism string
+	var lldp bool
+	var lldpWait time.Duration
+	var repeat int
+	var onlineVendor bool
+	var onlineVendorAPI string
+	flag.StringVar(&subnet, "subnet", "", "CIDR subnet(s) to scan, comma-separated (e.g. 192.168.1.0/24)")
+	flag.BoolVar(&onlineVendor, "online-vendor", false, "Look up vendors missing from the OUI file in an online API after the scan")
+	flag.StringVar(&onlineVendorAPI, "online-vendor-url", onlineVendorURL, "MAC vendor API used by -online-vendor; the OUI is appended to it")
+	flag.IntVar(&repeat, "repeat", 1, "Run the scan N times back to back and count in how many runs each host answered")
+	flag.BoolVar(&lldp, "lldp", false, "Listen for LLDP frames during the scan and match them to hosts by MAC")
+	flag.DurationVar(&lldpWait, "lldp-wait", 30*time.Second, "How long to listen for LLDP frames")
+	flag.StringVar(&subnetParallelism, "subnet-parallelism", SubnetParallelismInterleaved, "Scan several subnets interleaved in one pool, or serial: one after another")
+	flag.StringVar(&localSubnetIP, "local-subnet-of", "", "Scan the whole connected subnet of the local interface that reaches this IP")
+	flag.BoolVar(&allInterfaces, "all-interfaces", false, "Scan the subnet of every up interface from its own address and note which one reached each host")
+	flag.BoolVar(&scanner.ICMPPerHostSocket, "icmp-per-host-socket", false, "Open an ICMP socket for every host instead of one shared by the scan")
+	flag.BoolVar(&failOnPermission, "fail-fast-on-permission", false, "Exit at once if ICMP ping is not permitted instead of scanning without it")
 	flag.BoolVar(&useTCP, "tcp", false, "Use TCP connect scan instead of ICMP ping")
 	flag.BoolVar(&useUDP, "udp", false, "Perform UDP probe scan for common ports (open|filtered detection)")
+	flag.BoolVar(&scanner.TCPRefusedUp, "tcp-rst-up", false, "With -tcp, count a refused connection (RST) as the host being up")
+	flag.IntVar(&scanner.UDPConcurrency, "udp-concurrency", defaultUDPHostConcurrency, "UDP ports of one host to probe at once")
+	flag.BoolVar(&scanner.UDPSingleSocket, "udp-single-socket", false, "Probe all UDP ports of a host from one socket instead of one per port")
+	flag.IntVar(&scanner.MaxPortsPerHost, "max-ports-per-host", 0, "Stop probing a host's ports once this many are open (0 for no limit)")
+	flag.BoolVar(&scanner.UDPAll, "udp-all", false, "Run UDP probes against every target, even hosts that ignore ICMP and TCP (implies -udp)")
+	flag.DurationVar(&timeout, "timeout", defaultTimeout, "Per-probe timeout for every phase")
+	flag.DurationVar(&pingTimeout, "ping-timeout", 0, "ICMP echo timeout (defaults to -timeout)")
+	flag.DurationVar(&tcpTimeout, "tcp-timeout", 0, "TCP connect timeout (defaults to -timeout)")
+	flag.StringVar(&portTimeouts, "port-timeout", "", "TCP connect timeouts of slow ports, overriding -tcp-timeout (e.g. 3389=3s,25=2s)")
+	flag.DurationVar(&udpTimeout, "udp-timeout", 0, "UDP reply timeout (defaults to -timeout)")
+	flag.StringVar(&dohURL, "doh", "", "Resolve hostnames over DNS-over-HTTPS with this server (e.g. https://cloudflare-dns.com/dns-query)")
+	flag.BoolVar(&blockPublicDNS, "warn-on-public-dns", false, "Skip reverse lookups of private addresses that would go to a public DNS server")
+	flag.DurationVar(&scanner.ResolveTimeout, "resolve-timeout", defaultResolveTimeout, "Give up on a host's reverse DNS name after this long")
+	flag.BoolVar(&scanner.BatchPTR, "dns-ptr-batch", false, "Resolve hostnames in a separate stage after the scan")
+	flag.DurationVar(&deadline, "deadline", 0, "Stop the whole scan after this long and report what was found (0 for no limit)")
+	flag.BoolVar(&scanner.DetectForeignReplies, "detect-foreign-replies", false, "Report hosts whose ping reply comes from a different source IP")
+	flag.IntVar(&scanner.ProbeSize, "probe-size", 0, "ICMP echo payload size in bytes, for MTU testing (default 4)")
+	flag.BoolVar(&scanner.DontFragment, "dont-fragment", false, "Set the don't-fragment bit on ICMP echo requests")
+	flag.BoolVar(&scanner.ICMPFallback, "icmp-fallback", false, "Try ICMP timestamp and address mask requests on hosts that ignore ping")
+	flag.StringVar(&portSpec, "ports", "", "TCP ports to scan (e.g. 22,80,8000-8100)")
+	flag.StringVar(&portSpec, "p", "", "Shorthand for -ports")
+	flag.StringVar(&portsFilePath, "ports-file", "", "Load the default TCP and UDP port lists from a file of \"name port/proto\" lines")
+	flag.StringVar(&serviceSpec, "ports-from-services", "", "TCP ports to scan by service name (e.g. ssh,http,https,smb); adds to -ports")
+	flag.StringVar(&sourceIP, "source-ip", "", "Send all probes from this local address instead of the one the route picks")
+	flag.StringVar(&socks5, "socks5", "", "Run the TCP connect scan through this SOCKS5 proxy (host:port); disables ICMP and UDP")
+	flag.IntVar(&scanner.SourcePort, "source-port", 0, "Send TCP and UDP probes from this local port, for source-port ACLs (default: ephemeral)")
+	flag.StringVar(&udpPortSpec, "udp-ports", "", "UDP ports to probe with -udp, same syntax as -ports")
+	flag.StringVar(&excludeSpec, "exclude-ports", "", "Ports never to probe, same syntax as -ports")
+	flag.StringVar(&vendor, "vendor", "", "Only show hosts whose manufacturer contains this text (case-insensitive)")
+	flag.DurationVar(&minRTT, "min-rtt", 0, "Only show hosts whose ICMP response time is at least this long")
+	flag.DurationVar(&maxRTT, "max-rtt", 0, "Only show hosts whose ICMP response time is at most this long")
+	flag.StringVar(&statePath, "state", "", "File of the hosts found by the previous scan, replaced after each scan")
+	flag.BoolVar(&onlyNew, "only-new", false, "With -state, only show hosts the previous scan did not find")
+	flag.StringVar(&labelsPath, "labels", "", "CSV or JSON file of MAC or IP to a friendly label shown for matching hosts")
+	flag.StringVar(&baselinePath, "baseline", "", "IP to MAC baseline file; created if missing, otherwise changed and new MACs are flagged")
+	flag.StringVar(&groupBy, "group-by", "", "Group results by vendor or subnet")
+	flag.BoolVar(&ui.RankByPorts, "rank-by-ports", false, "Rank hosts by their number of open ports, most first")
+	flag.StringVar(&sortOrder, "sort", SortByIP, "Order hosts by ip or by discovery (first to answer first)")
+	flag.BoolVar(&scanner.IncludeNetworkBroadcast, "include-network-broadcast", false, "Also scan each subnet's network and broadcast addresses")
+	flag.BoolVar(&scanner.IncludeSelf, "include-self", false, "Probe this host's own addresses too instead of listing them unprobed")
+	flag.BoolVar(&scanner.RecordDown, "show-down", false, "Also list the scanned IPs that did not answer, marked down")
+	flag.BoolVar(&scanner.FirstOnly, "first-only", false, "Stop as soon as the first reachable host is found")
+	flag.IntVar(&scanner.StopAfterFound, "stop-after-found", 0, "Stop as soon as this many reachable hosts are found")
+	flag.IntVar(&scanner.MaxResults, "max-results", 0, "Keep a random sample of at most this many reachable hosts")
+	flag.BoolVar(&continueOnError, "continue-on-error", false, "Skip subnets that do not parse, resolve or route, and list them after the results")
+	flag.DurationVar(&scanner.macResolver.ARPTimeout, "arp-timeout", macaddr.DefaultARPTimeout, "Wait this long for the ARP table after each ARP request sent to find a MAC")
+	flag.IntVar(&scanner.macResolver.ARPRetries, "arp-retries", macaddr.DefaultARPRetries, "Further ARP requests to send when a MAC is still unknown")
+	flag.IntVar(&scanner.Concurrency, "concurrency", 0, "Hosts to probe at once (default: picked from the CPU and target count)")
+	flag.IntVar(&scanner.Count, "count", 1, "Number of ICMP echo requests per host (jitter is reported when >1)")
+	flag.IntVar(&scanner.ICMPRetries, "retry-icmp", 0, "Resend unanswered ICMP echoes up to N times with exponential backoff (0-5)")
+	flag.BoolVar(&ouiDisabled, "no-oui", false, "Skip vendor lookups and the OUI database download")
+	flag.BoolVar(&ouiFull, "oui-full", false, "Include each vendor's address and country from the OUI file in JSON output")
+	flag.StringVar(&ouiURL, "oui-url", ouiFileURL, "URL to download the OUI vendor database from (e.g. an internal mirror)")
+	flag.BoolVar(&ui.CountOnly, "count-only", false, "Print only the number of reachable hosts")
+	flag.BoolVar(&ui.Quiet, "quiet", false, "Hide the progress bar and status messages")
+	flag.DurationVar(&watch, "watch", 0, "Repeat the scan at this interval until interrupted")
+	flag.StringVar(&outputDir, "output-dir", "", "Also save each scan's results to a timestamped file in this directory")
+	flag.BoolVar(&interactive, "tui", false, "Show a live, interactive full-screen view of the scan")
+	flag.IntVar(&sample, "sample", 0, "Probe only N random addresses of the targets and estimate how many hosts are up")
+	flag.Int64Var(&seed, "seed", 0, "Random seed for -sample, to repeat a sample (default: random)")
+	flag.IntVar(&progressFD, "progress-fd", -1, "Also write progress updates as JSON lines to this file descriptor")
+	flag.StringVar(&ui.Format, "format", FormatTable, "Output format: table, json, dot or prom")
+	flag.BoolVar(&ui.JSONPretty, "json-pretty", false, "Indent JSON output for reading instead of one line per result")
+	flag.BoolVar(&ui.LatencyHistogram, "latency-histogram", false, "Summarize the hosts' ICMP response times in buckets")
+	flag.StringVar(&ui.MACFormat, "mac-format", MACFormatColon, "MAC address style: colon, hyphen, cisco or bare")
 	flag.Parse()
 
+	if err := validateFormat(ui.Format, FormatTable, FormatJSON, FormatDOT, FormatProm); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if err := validateMACFormat(ui.MACFormat); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if ui.Quiet {
+		ouiStatus = io.Discard
+	}
+
+	if progressFD >= 0 {
+		stream := os.NewFile(uintptr(progressFD), "progress-fd")
+		if _, err := stream.Stat(); err != nil {
+			ui.ShowError("Error opening -progress-fd", err)
+			os.Exit(1)
+		}
+		ui.ProgressStream = stream
+	}
+
+	if ouiDisabled && (vendor != "" || groupBy == GroupByVendor || onlineVendor) {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-no-oui cannot be combined with vendor filtering, grouping or -online-vendor"))
+		os.Exit(1)
+	}
+
+	if err := validateSubnetParallelism(subnetParallelism); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if err := validateSort(sortOrder); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if err := validateGroupBy(groupBy); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+	ui.GroupBy = groupBy
+
+	if scanner.Count < 1 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-count must be at least 1"))
+		os.Exit(1)
+	}
+
+	if scanner.ProbeSize < 0 || scanner.ProbeSize > maxProbeSize {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-probe-size must be between 0 and %d", maxProbeSize))
+		os.Exit(1)
+	}
+
+	if dohURL != "" {
+		doh, err := newDoHResolver(dohURL)
+		if err != nil {
+			ui.ShowError("Error parsing flags", err)
+			os.Exit(1)
+		}
+		scanner.resolver = doh
+	}
+
+	if server := publicDNSServer(scanner.lookupResolver()); server != "" {
+		scanner.dnsLeaks = &dnsLeaks{server: server, block: blockPublicDNS}
+	}
+
+	if scanner.SourcePort < 0 || scanner.SourcePort > 65535 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-source-port must be between 0 (ephemeral) and 65535"))
+		os.Exit(1)
+	}
+
+	if sourceIP != "" {
+		ip, err := localSourceIP(sourceIP)
+		if err != nil {
+			ui.ShowError("Error parsing flags", err)
+			os.Exit(1)
+		}
+		scanner.SourceIP = ip
+	}
+
+	// The live view lists each host as it is found, so it needs the name then
+	if scanner.BatchPTR && interactive {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-dns-ptr-batch cannot be used with -tui"))
+		os.Exit(1)
+	}
+	scanner.ResolveStarted = ui.ShowResolveStart
+
+	if scanner.Concurrency < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-concurrency must not be negative"))
+		os.Exit(1)
+	}
+
+	if scanner.MaxPortsPerHost < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-max-ports-per-host must not be negative"))
+		os.Exit(1)
+	}
+
+	if scanner.UDPConcurrency < 1 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-udp-concurrency must be at least 1"))
+		os.Exit(1)
+	}
+
+	if scanner.macResolver.ARPRetries < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-arp-retries must not be negative"))
+		os.Exit(1)
+	}
+
+	if onlyNew && statePath == "" {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-only-new needs a -state file"))
+		os.Exit(1)
+	}
+
+	// Read the labels before scanning so a broken file fails fast
+	var labels *hostLabels
+	if labelsPath != "" {
+		var err error
+		if labels, err = loadLabels(labelsPath); err != nil {
+			ui.ShowError("Error loading labels", err)
+			os.Exit(1)
+		}
+	}
+
+	if scanner.StopAfterFound < 0 || scanner.MaxResults < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-stop-after-found and -max-results must not be negative"))
+		os.Exit(1)
+	}
+
+	if sample < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-sample must not be negative"))
+		os.Exit(1)
+	}
+
+	if repeat < 1 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-repeat must be at least 1"))
+		os.Exit(1)
+	}
+
+	if scanner.ICMPRetries < 0 || scanner.ICMPRetries > maxICMPRetries {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-retry-icmp must be between 0 and %d", maxICMPRetries))
+		os.Exit(1)
+	}
+
+	if minRTT < 0 || maxRTT < 0 || (maxRTT > 0 && minRTT > maxRTT) {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-min-rtt and -max-rtt must be positive with min <= max"))
+		os.Exit(1)
+	}
+
+	if timeout <= 0 || pingTimeout < 0 || tcpTimeout < 0 || udpTimeout < 0 || deadline < 0 || watch < 0 || scanner.ResolveTimeout <= 0 || lldpWait <= 0 || scanner.macResolver.ARPTimeout < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("timeouts must be positive"))
+		os.Exit(1)
+	}
+	scanner.PingTimeout = cmp.Or(pingTimeout, timeout)
+	scanner.TCPTimeout = cmp.Or(tcpTimeout, timeout)
+	scanner.UDPTimeout = cmp.Or(udpTimeout, timeout)
+	if portTimeouts != "" {
+		var err error
+		if scanner.PortTimeouts, err = parsePortTimeouts(portTimeouts); err != nil {
+			ui.ShowError("Error parsing flags", fmt.Errorf("-port-timeout: %w", err))
+			os.Exit(1)
+		}
+	}
+
 	// Set scan method
 	scanner.UseTCP = useTCP
-	scanner.UseUDP = useUDP
+	scanner.UseUDP = useUDP || scanner.UDPAll
 
-	// Support positional argument as subnet
-	if subnet == "" && flag.NArg() > 0 {
-		subnet = flag.Arg(0)
+	if socks5 != "" {
+		// Only TCP connections go through the proxy; pings and UDP probes
+		// would be sent from this host and find a different network
+		if scanner.UseUDP {
+			ui.ShowError("Error parsing flags", fmt.Errorf("-udp cannot be used with -socks5"))
+			os.Exit(1)
+		}
+		d, err := socks5Dialer(socks5, scanner.probeDialer(scanner.TCPTimeout))
+		if err != nil {
+			ui.ShowError("Error parsing flags", err)
+			os.Exit(1)
+		}
+		scanner.dialer = d
+		scanner.UseTCP = true
+		scanner.SkipICMP = true
+	}
+
+	if ui.RankByPorts && !scanner.UseTCP && !scanner.UseUDP {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-rank-by-ports needs -tcp or -udp to find open ports"))
+		os.Exit(1)
 	}
 
-	if subnet == "" {
+	ui.ShowJitter = scanner.Count > 1
+	ui.ShowTries = scanner.ICMPRetries > 0
+
+	// Support positional arguments as subnets
+	subnets := splitList(subnet)
+	if len(subnets) == 0 {
+		subnets = flag.Args()
+	}
+
+	if localSubnetIP != "" {
+		cidr, err := localSubnetOf(localSubnetIP)
+		if err != nil {
+			ui.ShowError("Error finding local subnet", err)
+			os.Exit(1)
+		}
+		subnets = append(subnets, cidr)
+	}
+
+	// Each interface's subnet is scanned from that interface's address
+	var links []subnetTargets
+	if allInterfaces {
+		if len(subnets) > 0 || scanner.SourceIP != nil || sample > 0 {
+			ui.ShowError("Error parsing flags", fmt.Errorf("-all-interfaces picks the subnets and source addresses; it cannot be combined with subnets, -source-ip or -sample"))
+			os.Exit(1)
+		}
+		var err error
+		if links, err = localInterfaceTargets(); err != nil {
+			ui.ShowError("Error listing interfaces", err)
+			os.Exit(1)
+		}
+		if len(links) == 0 {
+			ui.ShowError("Error listing interfaces", fmt.Errorf("no up interface has an IPv4 subnet to scan"))
+			os.Exit(1)
+		}
+		for _, link := range links {
+			subnets = append(subnets, link.Subnet)
+		}
+	}
+
+	// With no targets, scan the local IPv4 subnets and look for IPv6 neighbors
+	autoTargets := len(subnets) == 0
+	if autoTargets {
+		subnets = localScanTargets()
+	}
+
+	var failedSubnets SubnetErrors
+	if continueOnError {
+		subnets, failedSubnets = usableSubnets(scanner, subnets)
+		if len(subnets) == 0 && len(failedSubnets) > 0 {
+			ui.ShowError("Error parsing subnet", failedSubnets)
+			os.Exit(exitCode(failedSubnets))
+		}
+		if len(failedSubnets) > 0 {
+			ui.ShowStatus("(Skipping %d subnets that cannot be scanned, listed after the results)", len(failedSubnets))
+		}
+	}
+
+	if len(subnets) == 0 && (!autoTargets || sample > 0) {
 		ui.ShowUsage(filepath.Base(os.Args[0]))
 		os.Exit(1)
 	}
+	ui.Subnets = subnets
 
-	ips, err := scanner.GetIPsFromSubnet(subnet)
+	var groups []subnetTargets
+	var space *big.Int
+	var err error
+	if sample > 0 {
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		groups, space, err = sampleTargets(scanner, subnets, sample, rand.New(rand.NewSource(seed)))
+		if err == nil {
+			ui.ShowStatus("(Sampling %d of %s addresses, -seed %d)", len(allTargets(groups)), space, seed)
+		}
+	} else {
+		groups, err = collectTargets(scanner, subnets)
+	}
 	if err != nil {
 		ui.ShowError("Error parsing subnet", err)
+		os.Exit(exitCode(err))
+	}
+	for _, link := range links {
+		for i := range groups {
+			if groups[i].Subnet == link.Subnet {
+				groups[i].Interface, groups[i].Source = link.Interface, link.Source
+			}
+		}
+	}
+	if autoTargets && sample == 0 {
+		groups = append(groups, discoverIPv6Targets(ui, scanner)...)
+		subnets = nil
+		for _, group := range groups {
+			subnets = append(subnets, group.Subnet)
+		}
+		if len(subnets) == 0 {
+			ui.ShowUsage(filepath.Base(os.Args[0]))
+			os.Exit(1)
+		}
+		ui.Subnets = subnets
+		ui.ShowStatus("(No targets given, scanning %s)", strings.Join(subnets, ", "))
+	}
+	ips := allTargets(groups)
+
+	if portsFilePath != "" {
+		pf, err := loadPortsFile(portsFilePath)
+		if err != nil {
+			ui.ShowError("Error loading ports file", err)
+			os.Exit(1)
+		}
+		applyPortsFile(scanner, pf)
+	} else if scanner.UseTCP && portSpec == "" && serviceSpec == "" {
+		var choice string
+		scanner.TCPPorts, choice = defaultTCPPortsFor(ips)
+		ui.ShowStatus("(No TCP ports given, probing %s)", choice)
+	}
+
+	if err := configurePorts(scanner, portSpec, serviceSpec, udpPortSpec, excludeSpec); err != nil {
+		ui.ShowError("Error parsing ports", err)
 		os.Exit(1)
 	}
 
-	ui.ShowScanStart(subnet, len(ips))
+	if scanner.SkipICMP {
+		ui.ShowStatus("(Probing TCP ports through SOCKS5 proxy %s; ICMP and ARP are skipped)", socks5)
+	} else if err := scanner.CheckICMP(); errors.Is(err, ErrNoRawSocket) {
+		if failOnPermission {
+			// Every ping would fail the same way, so stop before probing anything
+			ui.ShowError("Error opening ICMP socket", fmt.Errorf("%w; to grant it: sudo setcap cap_net_raw+ep %s", err, os.Args[0]))
+			os.Exit(exitCode(err))
+		}
+		ui.ShowWarning("ICMP ping disabled, only TCP and UDP probes can find hosts", err)
+		// Otherwise every host would try to open the raw socket again
+		scanner.SkipICMP = true
+	}
+
+	if interactive {
+		ensureOUIFile(ui, ouiURL)
+		if err := runTUI(scanner, ips, strings.Join(subnets, ", ")); err != nil {
+			ui.ShowError("Error starting interactive view", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if outputDir != "" {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			ui.ShowError("Error creating output directory", err)
+			os.Exit(1)
+		}
+	}
+
+	self := localContext(groups, scanner.SourceIP)
+	history := newHostHistory()
+	for cycle := 1; ; cycle++ {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if deadline > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), deadline)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		var neighbors *lldpCollector
+		if lldp {
+			neighbors = startLLDP(lldpInterface(ips), lldpWait)
+		}
+
+		started := time.Now()
+		scan := func(ctx context.Context) *ScanResult {
+			if allInterfaces || (subnetParallelism == SubnetParallelismSerial && len(groups) > 1) {
+				return scanSerially(ctx, ui, scanner, groups)
+			}
+			ui.ShowScanStart(strings.Join(subnets, ", "), len(ips))
+			return scanner.ScanSubnetContext(ctx, ips, ui.ShowProgress)
+		}
+
+		var result *ScanResult
+		if repeat > 1 {
+			result = repeatScan(ctx, ui, repeat, scan)
+			ui.Runs = result.Runs
+		} else {
+			result = scan(ctx)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			ui.ShowStatus("(Deadline of %s reached)", deadline)
+		}
+		cancel()
+		result.Meta = newScanMeta(scanner, subnets, started, time.Now())
+		result.Meta.Self = self
 
-	result := scanner.ScanSubnet(ips, ui.ShowProgress)
+		if neighbors != nil {
+			addLLDPNeighbors(ui, neighbors, result)
+		}
 
-	updateOUIFile()
+		if leaked := scanner.dnsLeaks.take(); len(leaked) > 0 {
+			message := fmt.Sprintf("Reverse lookups of %d private addresses were sent to public DNS server %s", len(leaked), scanner.dnsLeaks.server)
+			if blockPublicDNS {
+				message = fmt.Sprintf("Skipped reverse lookups of %d private addresses that would go to public DNS server %s", len(leaked), scanner.dnsLeaks.server)
+			}
+			ui.stopProgress()
+			ui.ShowWarning(message, errors.New(summarizeLeaks(leaked)))
+		}
+
+		if scanner.ProbeSize > 0 || scanner.DontFragment {
+			showProbeSizeResult(ui, scanner, result)
+		}
+
+		// A bare count needs no vendor data unless results are filtered by vendor
+		if cycle == 1 && (!ui.CountOnly || vendor != "") {
+			ensureOUIFile(ui, ouiURL)
+		}
+		if onlineVendor && !ui.CountOnly {
+			resolveOnlineVendors(ui, onlineVendorAPI, result.ReachableHosts)
+		}
+
+		if watch > 0 {
+			history.merge(result, started)
+		}
+		if labels != nil {
+			labels.apply(result.ReachableHosts)
+		}
+
+		var filters []hostFilter
+		if statePath != "" {
+			previous, err := loadState(statePath)
+			if err != nil {
+				ui.ShowError("Error using state file", err)
+				os.Exit(1)
+			}
+			if _, err := saveState(statePath, result.ReachableHosts); err != nil {
+				ui.ShowError("Error using state file", err)
+				os.Exit(1)
+			}
+			if onlyNew {
+				filters = append(filters, newHostFilter(previous))
+			}
+		}
+		if vendor != "" {
+			filters = append(filters, vendorFilter(vendor))
+		}
+		if minRTT > 0 || maxRTT > 0 {
+			filters = append(filters, rttFilter(minRTT, maxRTT))
+		}
+		applyFilters(result, filters)
+		sortHosts(result.ReachableHosts, sortOrder)
+
+		if sample > 0 && result.Completed > 0 {
+			found := onlineHosts(result.ReachableHosts) + result.Filtered + result.Dropped
+			ui.stopProgress()
+			ui.ShowStatus("(Sample: %d of %d addresses up, %.1f%%; about %s hosts in all %s addresses)",
+				found, result.Completed, 100*float64(found)/float64(result.Completed), estimateHosts(found, result.Completed, space), space)
+		}
+
+		if baselinePath != "" {
+			if err := checkBaseline(ui, baselinePath, result); err != nil {
+				ui.ShowError("Error using baseline", err)
+				os.Exit(1)
+			}
+		}
+
+		showPorts := scanner.UseTCP || scanner.UseUDP
+		ui.ShowResults(result, showPorts)
+
+		if outputDir != "" {
+			path, err := ui.SaveResults(outputDir, started, result, showPorts)
+			if err != nil {
+				ui.ShowError("Error saving results", err)
+				os.Exit(1)
+			}
+			ui.ShowStatus("(Saved results to %s)", path)
+		}
+
+		if watch <= 0 {
+			if failedSubnets != nil {
+				// On stderr, so JSON results on stdout stay parseable
+				ui.ShowWarning("Some subnets were not scanned", failedSubnets)
+				os.Exit(exitCode(failedSubnets))
+			}
+			return
+		}
+		ui.ShowStatus("(Next scan in %s)", watch)
+		time.Sleep(watch)
+	}
+}
+
+// Exit codes that let scripts tell failure modes apart
+const (
+	exitError         = 1
+	exitInvalidSubnet = 2
+	exitNoRawSocket   = 3
+)
+
+// exitCode maps an error to the process exit code
+func exitCode(err error) int {
+	if errors.Is(err, ErrInvalidSubnet) {
+		return exitInvalidSubnet
+	}
+	if errors.Is(err, ErrNoRawSocket) {
+		return exitNoRawSocket
+	}
+	return exitError
+}
+
+// ensureOUIFile downloads the OUI database if needed, warning when vendors
+// cannot be looked up
+func ensureOUIFile(ui *UI, url string) {
+	if ouiDisabled {
+		return
+	}
+	if err := updateOUIFile(url); errors.Is(err, ErrOUIUnavailable) {
+		ui.ShowWarning("Vendor names will be missing", err)
+	}
+}
+
+// addLLDPNeighbors waits for the LLDP listening window to end and annotates
+// the hosts that advertised themselves
+func addLLDPNeighbors(ui *UI, neighbors *lldpCollector, result *ScanResult) {
+	ui.ShowStatus("(Listening for LLDP frames until the window ends)")
+	found, err := neighbors.wait()
+	if err != nil {
+		ui.ShowWarning("LLDP neighbors will be missing", err)
+		return
+	}
+	matched := annotateLLDP(result, found)
+	ui.ShowStatus("(LLDP: %d hosts matched)", matched)
+}
+
+// showProbeSizeResult reports how many targets answered the sized echo requests
+func showProbeSizeResult(ui *UI, scanner *Scanner, result *ScanResult) {
+	answered := 0
+	for _, host := range result.ReachableHosts {
+		if host.ICMPResponseTime > 0 {
+			answered++
+		}
+	}
+
+	df := ""
+	if scanner.DontFragment {
+		df = " with don't-fragment set"
+	}
+	ui.ShowStatus("(%d/%d targets answered %d-byte pings%s)", answered, result.Completed, len(scanner.echoPayload()), df)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// collectTargets expands every subnet into its target list, skipping addresses
+// that overlapping subnets would otherwise repeat
+func collectTargets(scanner *Scanner, subnets []string) ([]subnetTargets, error) {
+	var groups []subnetTargets
+	seen := make(map[string]bool)
+
+	for _, subnet := range subnets {
+		subnetIPs, err := scanner.GetIPsFromSubnet(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", subnet, err)
+		}
+
+		group := subnetTargets{Subnet: subnet}
+		for _, ip := range subnetIPs {
+			if !seen[ip] {
+				seen[ip] = true
+				group.IPs = append(group.IPs, ip)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// discoverIPv6Targets finds the IPv6 neighbors on every local link, one
+// target group per link. IPv6 prefixes are too large to sweep, so this is
+// how a scan without targets covers IPv6.
+func discoverIPv6Targets(ui *UI, scanner *Scanner) []subnetTargets {
+	links, err := localIPv6Links()
+	if err != nil {
+		ui.ShowWarning("IPv6 neighbor discovery skipped", err)
+		return nil
+	}
+
+	var groups []subnetTargets
+	for _, link := range links {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*scanner.PingTimeout)
+		found, err := scanner.discoverNeighbors(ctx, link)
+		cancel()
+		if err != nil {
+			ui.ShowWarning("IPv6 neighbor discovery skipped", err)
+			if errors.Is(err, ErrNoRawSocket) {
+				return groups
+			}
+			continue
+		}
+		if len(found) > 0 {
+			groups = append(groups, subnetTargets{Subnet: link.target(), IPs: found})
+		}
+	}
+	return groups
+}
+
+// checkBaseline flags hosts that differ from the baseline file, or saves the
+// current hosts as the baseline when the file does not exist yet
+func checkBaseline(ui *UI, path string, result *ScanResult) error {
+	b, err := loadBaseline(path)
+	if err != nil {
+		return err
+	}
+
+	if b == nil {
+		saved, err := saveBaseline(path, result.ReachableHosts)
+		if err != nil {
+			return err
+		}
+		ui.ShowStatus("(Saved baseline of %d hosts to %s)", saved, path)
+		return nil
+	}
+
+	if flagged := b.annotate(result.ReachableHosts); flagged > 0 {
+		ui.ShowStatus("(%d hosts differ from baseline %s)", flagged, path)
+	}
+	return nil
+}
+
+// applyPortsFile makes the ports in pf the lists scanned when -ports and
+// -udp-ports are not given, and its names known to -ports-from-services
+func applyPortsFile(scanner *Scanner, pf *portsFile) {
+	if len(pf.TCP) > 0 {
+		scanner.TCPPorts = pf.TCP
+	}
+	if len(pf.UDP) > 0 {
+		scanner.UDPPorts = pf.UDP
+	}
+	for name, port := range pf.Services {
+		servicePorts[name] = port
+	}
+}
+
+// configurePorts applies the -ports, -udp-ports and -exclude-ports specs to the
+// scanner. Exclusions are applied last so they always win.
+func configurePorts(scanner *Scanner, portSpec, serviceSpec, udpPortSpec, excludeSpec string) error {
+	if portSpec != "" {
+		ports, err := parsePortSpec(portSpec)
+		if err != nil {
+			return err
+		}
+		scanner.TCPPorts = ports
+	}
+
+	// Services add to -ports when both are given
+	if serviceSpec != "" {
+		ports, err := parseServiceSpec(serviceSpec)
+		if err != nil {
+			return err
+		}
+		if portSpec == "" {
+			scanner.TCPPorts = nil
+		}
+		for _, port := range ports {
+			if !slices.Contains(scanner.TCPPorts, port) {
+				scanner.TCPPorts = append(scanner.TCPPorts, port)
+			}
+		}
+	}
+
+	if udpPortSpec != "" {
+		ports, err := parsePortSpec(udpPortSpec)
+		if err != nil {
+			return err
+		}
+		scanner.UDPPorts = ports
+	}
+
+	if excludeSpec != "" {
+		exclude, err := parsePortSpec(excludeSpec)
+		if err != nil {
+			return err
+		}
+		scanner.TCPPorts = excludePorts(scanner.TCPPorts, exclude)
+		scanner.UDPPorts = excludePorts(scanner.UDPPorts, exclude)
+	}
+
+	if scanner.UseTCP && len(scanner.TCPPorts) == 0 {
+		return fmt.Errorf("no TCP ports left to scan")
+	}
+	if scanner.UseUDP && len(scanner.UDPPorts) == 0 {
+		return fmt.Errorf("no UDP ports left to scan")
+	}
+	return nil
+}
+
+// runInterfaces implements the "interfaces" subcommand
+func runInterfaces(ui *UI, args []string) {
+	fs := flag.NewFlagSet("interfaces", flag.ExitOnError)
+	fs.StringVar(&ui.Format, "format", FormatTable, "Output format: table or json")
+	fs.BoolVar(&ui.JSONPretty, "json-pretty", false, "Indent JSON output for reading")
+	fs.Parse(args)
+
+	if err := validateFormat(ui.Format, FormatTable, FormatJSON); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	interfaces, err := listInterfaces()
+	if err != nil {
+		ui.ShowError("Error listing interfaces", err)
+		os.Exit(1)
+	}
+
+	ui.ShowInterfaces(interfaces)
+}
+
+// runRender implements the "render" subcommand, re-rendering saved JSON
+// results in another format without scanning again
+func runRender(ui *UI, args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	fs.StringVar(&ui.Format, "format", FormatTable, "Output format: table, json, dot or prom")
+	fs.BoolVar(&ui.JSONPretty, "json-pretty", false, "Indent JSON output for reading")
+	fs.StringVar(&ui.MACFormat, "mac-format", MACFormatColon, "MAC address style: colon, hyphen, cisco or bare")
+	fs.BoolVar(&ui.CountOnly, "count-only", false, "Print only the number of reachable hosts")
+	fs.BoolVar(&ui.LatencyHistogram, "latency-histogram", false, "Summarize the hosts' ICMP response times in buckets")
+	fs.BoolVar(&ui.RankByPorts, "rank-by-ports", false, "Rank hosts by their number of open ports, most first")
+	ouiURL := fs.String("oui-url", ouiFileURL, "URL to download the OUI vendor database from")
+	fs.BoolVar(&ouiFull, "oui-full", false, "Include each vendor's address and country in JSON output")
+	fs.BoolVar(&ouiDisabled, "no-oui", false, "Skip vendor lookups and the OUI database download")
+	fs.Parse(args)
+
+	if err := validateFormat(ui.Format, FormatTable, FormatJSON, FormatDOT, FormatProm); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+	if err := validateMACFormat(ui.MACFormat); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if fs.NArg() != 1 {
+		ui.ShowUsage(filepath.Base(os.Args[0]))
+		os.Exit(1)
+	}
+
+	results, err := loadResults(fs.Arg(0))
+	if err != nil {
+		ui.ShowError("Error loading results", err)
+		os.Exit(1)
+	}
+
+	ensureOUIFile(ui, *ouiURL)
+
+	for _, result := range results {
+		ui.Runs = result.Runs
+		showPorts := false
+		ui.ShowJitter, ui.ShowTries = false, false
+		for _, host := range result.ReachableHosts {
+			if len(host.OpenPorts) > 0 || len(host.OpenUDPPorts) > 0 {
+				showPorts = true
+			}
+			if host.RTTJitter > 0 {
+				ui.ShowJitter = true
+			}
+			if host.ICMPTries > 0 {
+				ui.ShowTries = true
+			}
+		}
+		if err := ui.writeResults(os.Stdout, result, showPorts); err != nil {
+			ui.ShowError("Error writing results", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// runVendor implements the "vendor" subcommand, looking up MACs without scanning
+func runVendor(ui *UI, args []string) {
+	fs := flag.NewFlagSet("vendor", flag.ExitOnError)
+	fs.StringVar(&ui.Format, "format", FormatTable, "Output format: table or json")
+	fs.BoolVar(&ui.JSONPretty, "json-pretty", false, "Indent JSON output for reading")
+	ouiURL := fs.String("oui-url", ouiFileURL, "URL to download the OUI vendor database from")
+	fs.BoolVar(&ouiFull, "oui-full", false, "Include each vendor's address and country in JSON output")
+	fs.Parse(args)
+
+	if err := validateFormat(ui.Format, FormatTable, FormatJSON); err != nil {
+		ui.ShowError("Error parsing flags", err)
+		os.Exit(1)
+	}
+
+	if fs.NArg() == 0 {
+		ui.ShowUsage(filepath.Base(os.Args[0]))
+		os.Exit(1)
+	}
+
+	ensureOUIFile(ui, *ouiURL)
+
+	var vendors []VendorInfo
+	for _, mac := range fs.Args() {
+		vendors = append(vendors, VendorInfo{MAC: mac, Manufacturer: mac2manufacturer(mac), Reason: vendorReason(mac), Details: vendorDetails(mac)})
+	}
 
-	ui.ShowResults(result, useTCP || useUDP)
+	ui.ShowVendors(vendors)
 }
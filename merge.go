@@ -0,0 +1,124 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+// mergeHosts combines hosts that share an IP into a single entry, keeping the
+// order in which each IP first appears. A target probed twice (listed twice by
+// a library caller, or probed by several sources) would otherwise produce
+// duplicate rows.
+func mergeHosts(hosts []HostInfo) []HostInfo {
+	index := make(map[string]int, len(hosts))
+	merged := hosts[:0:0]
+	for _, host := range hosts {
+		i, ok := index[host.IP]
+		if !ok {
+			index[host.IP] = len(merged)
+			merged = append(merged, host)
+			continue
+		}
+		merged[i] = mergeHost(merged[i], host)
+	}
+	return merged
+}
+
+// mergeHost combines two results for the same IP. Anything one of them learned
+// is kept: the first non-empty MAC and names, the union of open ports and
+// notes, and the fastest response time.
+func mergeHost(a, b HostInfo) HostInfo {
+	if !a.Up && b.Up {
+		a, b = b, a
+	}
+	if !b.Up {
+		return a
+	}
+
+	a.MAC = cmp.Or(a.MAC, b.MAC)
+	a.Hostname = cmp.Or(a.Hostname, b.Hostname)
+	a.RequestedName = cmp.Or(a.RequestedName, b.RequestedName)
+	a.ResponderIP = cmp.Or(a.ResponderIP, b.ResponderIP)
+	a.Label = cmp.Or(a.Label, b.Label)
+	a.Interface = cmp.Or(a.Interface, b.Interface)
+	a.LLDPSystemName = cmp.Or(a.LLDPSystemName, b.LLDPSystemName)
+	a.LLDPPort = cmp.Or(a.LLDPPort, b.LLDPPort)
+
+	if b.ICMPResponseTime > 0 && (a.ICMPResponseTime == 0 || b.ICMPResponseTime < a.ICMPResponseTime) {
+		a.ICMPResponseTime, a.RTTJitter = b.ICMPResponseTime, b.RTTJitter
+	}
+	a.ICMPTries += b.ICMPTries
+	a.ProcessTime = max(a.ProcessTime, b.ProcessTime)
+	if b.Seq > 0 && (a.Seq == 0 || b.Seq < a.Seq) {
+		a.Seq = b.Seq
+	}
+
+	a.OpenPorts = unionPorts(a.OpenPorts, b.OpenPorts)
+	a.OpenUDPPorts = unionPorts(a.OpenUDPPorts, b.OpenUDPPorts)
+	a.TCPPortResults = mergePortResults(a.TCPPortResults, b.TCPPortResults)
+	a.UDPResults = mergeUDPResults(a.UDPResults, b.UDPResults)
+	for _, note := range b.Notes {
+		if !slices.Contains(a.Notes, note) {
+			a.Notes = append(slices.Clip(a.Notes), note)
+		}
+	}
+	return a
+}
+
+// unionPorts returns the sorted ports found in either list
+func unionPorts(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	ports := slices.Concat(a, b)
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
+
+// mergePortResults combines TCP port results, keeping the faster handshake
+// of a port found in both
+func mergePortResults(a, b []TCPPortResult) []TCPPortResult {
+	if len(b) == 0 {
+		return a
+	}
+
+	byPort := make(map[int]TCPPortResult, len(a)+len(b))
+	for _, result := range slices.Concat(a, b) {
+		if seen, ok := byPort[result.Port]; !ok || result.RTT < seen.RTT {
+			byPort[result.Port] = result
+		}
+	}
+
+	results := make([]TCPPortResult, 0, len(byPort))
+	for _, result := range byPort {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	return results
+}
+
+// udpStateRank orders UDP port states from the least to the most conclusive
+var udpStateRank = map[string]int{UDPOpenFiltered: 1, UDPClosed: 2, UDPOpen: 3}
+
+// mergeUDPResults combines UDP port results, keeping the most conclusive
+// state of a port probed in both
+func mergeUDPResults(a, b []UDPPortResult) []UDPPortResult {
+	if len(b) == 0 {
+		return a
+	}
+
+	byPort := make(map[int]UDPPortResult, len(a)+len(b))
+	for _, result := range slices.Concat(a, b) {
+		if seen, ok := byPort[result.Port]; !ok || udpStateRank[result.State] > udpStateRank[seen.State] {
+			byPort[result.Port] = result
+		}
+	}
+
+	results := make([]UDPPortResult, 0, len(byPort))
+	for _, result := range byPort {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	return results
+}
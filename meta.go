@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// version is the neti release, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+// netiVersion returns the release neti was built as, falling back to the
+// module version recorded by go install, or "dev"
+func netiVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// ScanMeta describes how a scan was run, so saved results document themselves
+type ScanMeta struct {
+	Targets     []string
+	Started     time.Time
+	Finished    time.Time
+	Version     string
+	Host        string        // Name of the machine that ran the scan
+	Concurrency int           // Hosts probed at once; 0 when chosen automatically
+	Timeout     time.Duration // ICMP echo timeout
+	Probes      []string      // Discovery methods used, e.g. icmp, tcp
+	Self        *SelfContext  // Where the scanning host sat on the network; nil when unknown
+}
+
+// SelfContext describes the scanning host's own place on the network, so a
+// report read later shows where the scan was run from
+type SelfContext struct {
+	IP        string // Local address the targets were reached from
+	MAC       string
+	Interface string
+	Subnet    string // Network of IP, e.g. 192.168.1.0/24
+	Gateway   string // Default gateway; empty when unknown
+}
+
+// String summarizes the context on one line, under the table output header
+func (c *SelfContext) String() string {
+	var b strings.Builder
+	b.WriteString("Scanned from")
+	if c.IP != "" {
+		b.WriteString(" " + c.IP)
+	}
+	if c.MAC != "" {
+		b.WriteString(" (" + c.MAC + ")")
+	}
+	if c.Interface != "" {
+		b.WriteString(" on " + c.Interface)
+	}
+	if c.Subnet != "" {
+		b.WriteString(", subnet " + c.Subnet)
+	}
+	if c.Gateway != "" {
+		b.WriteString(", gateway " + c.Gateway)
+	}
+	return b.String()
+}
+
+// withMACFormat returns a copy of the context with its MAC written in style,
+// or nil when c is nil
+func (c *SelfContext) withMACFormat(style string) *SelfContext {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.MAC = formatMAC(c.MAC, style)
+	return &out
+}
+
+// newScanMeta records the settings of a scan of targets by scanner
+func newScanMeta(scanner *Scanner, targets []string, started, finished time.Time) *ScanMeta {
+	host, _ := os.Hostname()
+
+	var probes []string
+	for _, p := range scanner.probers() {
+		probes = append(probes, p.Name())
+	}
+
+	return &ScanMeta{
+		Targets:     targets,
+		Started:     started,
+		Finished:    finished,
+		Version:     netiVersion(),
+		Host:        host,
+		Concurrency: scanner.Concurrency,
+		Timeout:     scanner.PingTimeout,
+		Probes:      probes,
+	}
+}
+
+// String summarizes the metadata on one line, as the table output header
+func (m *ScanMeta) String() string {
+	concurrency := "auto"
+	if m.Concurrency > 0 {
+		concurrency = fmt.Sprint(m.Concurrency)
+	}
+	host := ""
+	if m.Host != "" {
+		host = " on " + m.Host
+	}
+
+	return fmt.Sprintf("neti %s%s: %s, %s to %s (probes %s, concurrency %s, timeout %s)",
+		m.Version, host, strings.Join(m.Targets, " "),
+		m.Started.Format(time.RFC3339), m.Finished.Format(time.RFC3339),
+		strings.Join(m.Probes, ","), concurrency, m.Timeout)
+}
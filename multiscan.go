@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// How several subnets are scanned, selected with -subnet-parallelism
+const (
+	SubnetParallelismInterleaved = "interleaved" // All targets share one worker pool
+	SubnetParallelismSerial      = "serial"      // Each subnet finishes before the next starts
+)
+
+// validateSubnetParallelism checks that the requested subnet mode is supported
+func validateSubnetParallelism(mode string) error {
+	switch mode {
+	case SubnetParallelismInterleaved, SubnetParallelismSerial:
+		return nil
+	}
+	return fmt.Errorf("unsupported subnet parallelism %q (use %s or %s)", mode, SubnetParallelismInterleaved, SubnetParallelismSerial)
+}
+
+// subnetTargets is the target list of one requested subnet
+type subnetTargets struct {
+	Subnet string
+	IPs    []string
+
+	// Set by -all-interfaces: the local interface the subnet is on, and its
+	// address the probes are sent from
+	Interface string
+	Source    net.IP
+}
+
+// allTargets returns the targets of every subnet in one list
+func allTargets(groups []subnetTargets) []string {
+	var ips []string
+	for _, group := range groups {
+		ips = append(ips, group.IPs...)
+	}
+	return ips
+}
+
+// scanSerially scans one subnet at a time, reporting a summary as each one
+// completes, and returns the combined result
+func scanSerially(ctx context.Context, ui *UI, scanner *Scanner, groups []subnetTargets) *ScanResult {
+	// A host limit counts the hosts found in earlier subnets too
+	limit := scanner.foundLimit()
+	firstOnly := scanner.FirstOnly
+	defer func() { scanner.FirstOnly, scanner.StopAfterFound = firstOnly, limit }()
+	if firstOnly {
+		scanner.FirstOnly, scanner.StopAfterFound = false, limit
+	}
+
+	source := scanner.SourceIP
+	defer func() { scanner.SourceIP = source }()
+
+	combined := &ScanResult{}
+	for _, group := range groups {
+		if limit > 0 {
+			remaining := limit - onlineHosts(combined.ReachableHosts)
+			if remaining <= 0 {
+				// Counted as targets left unprobed, so the scan reads as stopped early
+				combined.Total += len(group.IPs)
+				continue
+			}
+			scanner.StopAfterFound = remaining
+		}
+		name := group.Subnet
+		if group.Interface != "" {
+			name += " on " + group.Interface
+			scanner.SourceIP = group.Source
+		}
+		ui.ShowScanStart(name, len(group.IPs))
+		result := scanner.ScanSubnetContext(ctx, group.IPs, ui.ShowProgress)
+		ui.stopProgress()
+		ui.ShowStatus("\n(%s: %d/%d hosts responded)", name, onlineHosts(result.ReachableHosts), result.Total)
+
+		// Keep discovery order across subnets, which were scanned one after another
+		for i := range result.ReachableHosts {
+			if result.ReachableHosts[i].Seq > 0 {
+				result.ReachableHosts[i].Seq += len(combined.ReachableHosts)
+			}
+			result.ReachableHosts[i].Interface = group.Interface
+		}
+		combined.ReachableHosts = append(combined.ReachableHosts, result.ReachableHosts...)
+		combined.Total += result.Total
+		combined.Completed += result.Completed
+		combined.Dropped += result.Dropped
+		combined.Probes = combined.Probes.add(result.Probes)
+	}
+	return combined
+}
+
+// usableSubnets returns the subnets that can be scanned, for
+// -continue-on-error. A subnet is skipped when it does not parse or resolve,
+// or when there is no route to it, and the reasons are returned together.
+func usableSubnets(scanner *Scanner, subnets []string) ([]string, SubnetErrors) {
+	var usable []string
+	var failed SubnetErrors
+	for _, subnet := range subnets {
+		if err := checkSubnet(scanner, subnet); err != nil {
+			failed = append(failed, &SubnetError{Subnet: subnet, Err: err})
+			continue
+		}
+		usable = append(usable, subnet)
+	}
+	return usable, failed
+}
+
+// checkSubnet reports why subnet cannot be scanned, or nil if it can
+func checkSubnet(scanner *Scanner, subnet string) error {
+	space, err := newTargetSpace(scanner, subnet)
+	if err != nil {
+		return err
+	}
+	if space.size.Sign() == 0 {
+		return nil
+	}
+
+	// Connecting a UDP socket sends nothing but fails without a route
+	conn, err := net.Dial("udp", net.JoinHostPort(space.at(new(big.Int)), "9"))
+	if err != nil {
+		return fmt.Errorf("no route: %w", err)
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// allNodes is the link-local multicast group every IPv6 node listens on
+var allNodes = net.ParseIP("ff02::1")
+
+// ipv6Link is an interface with IPv6 addresses whose neighbors can be found
+// without sweeping its prefixes, which are far too large to scan
+type ipv6Link struct {
+	iface   net.Interface
+	sources []net.IP // Link-local first, then ULA and global addresses
+}
+
+// target names the discovery as a scan target, the multicast group on the link
+func (l ipv6Link) target() string {
+	return allNodes.String() + "%" + l.iface.Name
+}
+
+// localIPv6Links returns the up, multicast-capable interfaces that have an
+// IPv6 link-local address
+func localIPv6Links() ([]ipv6Link, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []ipv6Link
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		link := ipv6Link{iface: iface}
+		var routable []net.IP
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() != nil {
+				continue
+			}
+			if ipnet.IP.IsLinkLocalUnicast() {
+				link.sources = append(link.sources, ipnet.IP)
+			} else if ipnet.IP.IsGlobalUnicast() {
+				routable = append(routable, ipnet.IP)
+			}
+		}
+		if len(link.sources) > 0 {
+			link.sources = append(link.sources, routable...)
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// discoverNeighbors pings the all-nodes group on link from each of its
+// addresses and returns the addresses that answered. Neighbors reply from an
+// address that matches the source, so pinging from a ULA or global address
+// finds those addresses too, not only the link-local ones. Hosts that ignore
+// multicast echo requests, as Windows does by default, are not found.
+func (s *Scanner) discoverNeighbors(ctx context.Context, link ipv6Link) ([]string, error) {
+	c, err := listenICMP(icmpV6, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	conn := c.(*icmp.PacketConn).IPv6PacketConn()
+	// Our own replies would only list this host
+	conn.SetMulticastLoopback(false)
+	if err := conn.SetMulticastInterface(&link.iface); err != nil {
+		return nil, fmt.Errorf("%s: %w", link.iface.Name, err)
+	}
+
+	id := os.Getpid() & 0xffff
+	dst := &net.IPAddr{IP: allNodes, Zone: link.iface.Name}
+	for _, src := range link.sources {
+		message := &icmp.Message{
+			Type: ipv6.ICMPTypeEchoRequest,
+			Body: &icmp.Echo{ID: id, Seq: int(s.echoSeq.Add(1) & 0xffff), Data: s.echoPayload()},
+		}
+		data, err := message.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		cm := &ipv6.ControlMessage{Src: src, IfIndex: link.iface.Index}
+		if _, err := conn.WriteTo(data, cm, dst); err != nil {
+			s.logger.Debug("neighbor discovery send failed", "interface", link.iface.Name, "source", src, "error", err)
+			continue
+		}
+		s.probes.icmp.Add(1)
+		s.probes.bytes.Add(uint64(ipv6HeaderSize + len(data)))
+	}
+
+	// Replies to a multicast ping all arrive within about one round trip, so
+	// collect them for the ping timeout
+	deadline := time.Now().Add(s.PingTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	seen := make(map[string]bool)
+	var found []string
+	buf := make([]byte, 1500)
+	for {
+		n, _, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			break // The deadline passed
+		}
+		reply, err := icmp.ParseMessage(icmpV6.protocol, buf[:n])
+		if err != nil || reply.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); !ok || echo.ID != id {
+			continue
+		}
+
+		addr, ok := peer.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		ip := addr.IP.String()
+		if addr.IP.IsLinkLocalUnicast() {
+			ip = withZone(ip, link.iface.Name)
+		}
+		if !seen[ip] {
+			seen[ip] = true
+			found = append(found, ip)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Host orders for -sort
+const (
+	SortByIP        = "ip"
+	SortByDiscovery = "discovery"
+)
+
+// validateSort checks that the requested host order is supported
+func validateSort(order string) error {
+	switch order {
+	case SortByIP, SortByDiscovery:
+		return nil
+	}
+	return fmt.Errorf("unsupported sort order %q (use %s or %s)", order, SortByIP, SortByDiscovery)
+}
+
+// sortHosts puts hosts in the requested order. Scan results are already sorted
+// by IP; in discovery order the first hosts to answer come first, which brings
+// the closest and quickest devices to the top of a large scan. Hosts without a
+// discovery number, such as down hosts, stay last in IP order.
+func sortHosts(hosts []HostInfo, order string) {
+	if order != SortByDiscovery {
+		return
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		a, b := hosts[i].Seq, hosts[j].Seq
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+}
+
+// openPortCount is the number of open TCP and UDP ports found on a host
+func openPortCount(host HostInfo) int {
+	return len(host.OpenPorts) + len(host.OpenUDPPorts)
+}
+
+// rankByPorts orders hosts by their number of open ports, most first, for
+// -rank-by-ports. Hosts with as many open ports keep their order.
+func rankByPorts(hosts []HostInfo) {
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return openPortCount(hosts[i]) > openPortCount(hosts[j])
+	})
+}
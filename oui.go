@@ -8,53 +8,171 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 const ouiFileURL = "http://standards-oui.ieee.org/oui/oui.txt"
 const ouiFileName = "oui.txt"
 
+// OUI download limits. The whole download, including reading the body, must
+// finish within ouiDownloadTimeout.
+const (
+	ouiDownloadTimeout  = 2 * time.Minute
+	ouiDownloadAttempts = 3
+	ouiRetryBackoff     = time.Second
+)
+
+// ouiClient downloads the OUI file
+var ouiClient = &http.Client{Timeout: ouiDownloadTimeout}
+
+// ouiRecordMarker appears on every vendor line of a valid OUI file
+const ouiRecordMarker = "(base 16)"
+
+// Reasons a MAC address has no known manufacturer
+const (
+	vendorNoDB       = "no db"      // No OUI file could be loaded
+	vendorRandomized = "randomized" // Locally administered, usually a privacy MAC
+	vendorUnknown    = "unknown"    // OUI not in the database
+)
+
+// ouiStatus receives the OUI download status messages
+var ouiStatus io.Writer = os.Stderr
+
+// ouiDisabled turns off vendor resolution for -no-oui: the OUI file is never
+// downloaded or loaded and lookups return nothing
+var ouiDisabled bool
+
+// ouiFull keeps each vendor's address from the OUI file for -oui-full, so
+// vendorDetails can return it
+var ouiFull bool
+
+// OUIRecord is the full OUI file entry of a vendor
+type OUIRecord struct {
+	Name    string   `json:"name"`
+	Address []string `json:"address,omitempty"` // Street and city lines
+	Country string   `json:"country,omitempty"` // ISO 3166 country code
+}
+
 // OUI cache
 var (
 	ouiCache         map[string]string
+	ouiRecords       map[string]*OUIRecord // Only loaded with ouiFull
 	loadOUICacheOnce sync.Once
 )
 
-// updateOUIFile fetches the OUI file from the IEEE website and saves it locally.
-func updateOUIFile() error {
+// Memoized mac2manufacturer results, keyed by normalized MAC. Misses are stored
+// as empty strings so repeated unknown or randomized MACs are also O(1).
+var (
+	vendorCache   = make(map[string]string)
+	vendorCacheMu sync.RWMutex
+)
+
+// updateOUIFile fetches the OUI file from url (the IEEE website by default) and saves it locally.
+// Connection errors and server errors are retried with exponential backoff.
+// Failures are reported as ErrOUIUnavailable.
+func updateOUIFile(url string) error {
 	if _, err := os.Stat(ouiFileName); err == nil {
-		fmt.Printf("(OUI file already exists, skipping download.)")
+		fmt.Fprintf(ouiStatus, "(OUI file already exists, skipping download.)")
 		return nil
 	}
 
-	fmt.Printf("\n(Downloading OUI file from IEEE...)")
+	if url == "" {
+		url = ouiFileURL
+	}
+	if url == ouiFileURL {
+		fmt.Fprintf(ouiStatus, "\n(Downloading OUI file from IEEE...)")
+	} else {
+		fmt.Fprintf(ouiStatus, "\n(Downloading OUI file from %s...)", url)
+	}
 
-	resp, err := http.Get(ouiFileURL)
+	var err error
+	for attempt := 0; attempt < ouiDownloadAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(ouiRetryBackoff << (attempt - 1))
+			fmt.Fprintf(ouiStatus, "\n(Retrying OUI download, attempt %d of %d...)", attempt+1, ouiDownloadAttempts)
+		}
+
+		var retry bool
+		if retry, err = downloadOUIFile(url); err == nil || !retry {
+			break
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to download OUI file: %w", err)
+		return fmt.Errorf("%w: %w", ErrOUIUnavailable, err)
+	}
+	return nil
+}
+
+// downloadOUIFile downloads url into a temporary file that replaces the OUI file
+// only once the download is complete, so a partial download never corrupts the
+// cache. It reports whether a failure is worth retrying.
+func downloadOUIFile(url string) (retry bool, err error) {
+	resp, err := ouiClient.Get(url)
+	if err != nil {
+		return true, fmt.Errorf("failed to download OUI file: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download OUI file: received status code %d", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("failed to download OUI file: received status code %d", resp.StatusCode)
 	}
 
-	file, err := os.Create(ouiFileName)
+	// The temporary file sits next to the OUI file so the rename is atomic.
+	// A leftover from an interrupted run is simply overwritten.
+	tmpName := ouiFileName + ".tmp"
+	tmp, err := os.Create(tmpName)
 	if err != nil {
-		return fmt.Errorf("failed to create OUI file: %w", err)
+		return false, fmt.Errorf("failed to save OUI file: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(tmpName)
 
-	_, err = io.Copy(file, resp.Body)
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		return false, fmt.Errorf("failed to save OUI file: %w", closeErr)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to save OUI file: %w", err)
+		return true, fmt.Errorf("failed to download OUI file: %w", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return true, fmt.Errorf("failed to download OUI file: got %d of %d bytes", n, resp.ContentLength)
 	}
 
-	return nil
+	// Mirrors may answer with an error or login page; never cache that
+	if ok, err := fileContains(tmpName, ouiRecordMarker); err != nil {
+		return false, fmt.Errorf("failed to save OUI file: %w", err)
+	} else if !ok {
+		return false, fmt.Errorf("failed to download OUI file: response from %s is not an OUI file", url)
+	}
+
+	if err := os.Rename(tmpName, ouiFileName); err != nil {
+		return false, fmt.Errorf("failed to save OUI file: %w", err)
+	}
+	return false, nil
+}
+
+// fileContains reports whether any line of the file at path contains substr
+func fileContains(path, substr string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), substr) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
 }
 
 // loadOUICache loads the OUI file into an in-memory map.
 func loadOUICache() {
 	ouiCache = make(map[string]string)
+	if ouiFull {
+		ouiRecords = make(map[string]*OUIRecord)
+	}
 	file, err := os.Open(ouiFileName)
 	if err != nil {
 		// If the file doesn't exist, the cache will simply be empty.
@@ -63,10 +181,22 @@ func loadOUICache() {
 	}
 	defer file.Close()
 
+	// The indented lines after a vendor line hold its address, ending with
+	// the country code
+	var record *OUIRecord
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if !strings.Contains(line, "(base 16)") {
+		if !strings.Contains(line, ouiRecordMarker) {
+			if record == nil {
+				continue
+			}
+			if text := strings.TrimSpace(line); text != "" && strings.HasPrefix(line, "\t") {
+				record.Address = append(record.Address, text)
+			} else {
+				record.finish()
+				record = nil
+			}
 			continue
 		}
 
@@ -82,27 +212,125 @@ func loadOUICache() {
 		// The vendor is the second part, trimmed of whitespace.
 		vendor := strings.TrimSpace(parts[1])
 		ouiCache[ouiPrefix] = vendor
+
+		if ouiRecords != nil {
+			record.finish()
+			record = &OUIRecord{Name: vendor}
+			ouiRecords[ouiPrefix] = record
+		}
+	}
+	record.finish()
+}
+
+// finish moves the trailing country code out of the address lines
+func (r *OUIRecord) finish() {
+	if r == nil || len(r.Address) == 0 {
+		return
+	}
+	if last := r.Address[len(r.Address)-1]; len(last) == 2 {
+		r.Country = last
+		r.Address = r.Address[:len(r.Address)-1]
+	}
+}
+
+// vendorDetails returns the full OUI file entry for mac, or nil when -oui-full
+// is off or the vendor is unknown
+func vendorDetails(mac string) *OUIRecord {
+	if ouiDisabled || !ouiFull {
+		return nil
 	}
+	loadOUICacheOnce.Do(loadOUICache)
+
+	key := normalizeMAC(mac)
+	if len(key) < 6 {
+		return nil
+	}
+	return ouiRecords[key[:6]]
 }
 
 // mac2manufacturer looks up the manufacturer for a given MAC address from the in-memory OUI cache.
 func mac2manufacturer(mac string) string {
+	if ouiDisabled {
+		return ""
+	}
+
 	// Ensure the OUI cache is loaded, but only once.
 	loadOUICacheOnce.Do(loadOUICache)
 
-	// Normalize MAC to OUI prefix (e.g., 00:1A:2B:3C:4D:5E -> 001A2B)
-	macPrefix := strings.ToUpper(strings.ReplaceAll(mac, ":", ""))
-	if len(macPrefix) == 0 {
+	key := normalizeMAC(mac)
+	if len(key) == 0 {
 		return ""
 	}
-	if len(macPrefix) < 6 {
+
+	vendorCacheMu.RLock()
+	vendor, ok := vendorCache[key]
+	vendorCacheMu.RUnlock()
+	if ok {
+		return vendor
+	}
+
+	vendor = lookupManufacturer(key)
+
+	vendorCacheMu.Lock()
+	vendorCache[key] = vendor
+	vendorCacheMu.Unlock()
+
+	return vendor
+}
+
+// lookupManufacturer resolves a normalized MAC against the OUI database
+func lookupManufacturer(macHex string) string {
+	if len(macHex) < 6 {
 		return "Invalid MAC"
 	}
-	macPrefix = macPrefix[:6]
 
-	if vendor, ok := ouiCache[macPrefix]; ok {
+	// Normalize MAC to OUI prefix (e.g., 001A2B3C4D5E -> 001A2B)
+	if vendor, ok := ouiCache[macHex[:6]]; ok {
 		return vendor
 	}
 
-	return ""
+	// Prefixes missing from the OUI file may have been looked up online
+	return lookupOnlineVendor(macHex[:6])
+}
+
+// vendorReason explains why mac has no manufacturer, or returns "" when one is
+// known or there is no MAC at all
+func vendorReason(mac string) string {
+	if mac == "" || ouiDisabled || mac2manufacturer(mac) != "" {
+		return ""
+	}
+
+	// The locally administered bit is the second-lowest bit of the first octet
+	key := normalizeMAC(mac)
+	if len(key) < 6 {
+		return ""
+	}
+	if strings.ContainsRune("2367ABEF", rune(key[1])) {
+		return vendorRandomized
+	}
+	if len(ouiCache) == 0 {
+		return vendorNoDB
+	}
+	return vendorUnknown
+}
+
+// vendorLabel returns the manufacturer of mac for display, or the reason it is
+// unknown
+func vendorLabel(mac string) string {
+	if vendor := mac2manufacturer(mac); vendor != "" {
+		return vendor
+	}
+	return vendorReason(mac)
+}
+
+// normalizeMAC strips separators of any style (colons, hyphens, Cisco dots,
+// spaces) from a MAC address and returns the uppercase hex digits
+func normalizeMAC(mac string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(mac) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'F') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
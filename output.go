@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Output formats supported by the CLI
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatDOT   = "dot"
+	FormatProm  = "prom" // Prometheus text exposition format
+)
+
+// outputFileLayout names saved result files after the scan start time. It is
+// RFC 3339 with the colons dropped, which Windows does not allow in file names.
+const outputFileLayout = "2006-01-02T150405Z0700"
+
+// formatExtension returns the file extension for results saved in format
+func formatExtension(format string) string {
+	switch format {
+	case FormatJSON:
+		return ".json"
+	case FormatDOT:
+		return ".dot"
+	case FormatProm:
+		return ".prom"
+	}
+	return ".txt"
+}
+
+// validateFormat checks that the requested output format is one of formats
+func validateFormat(format string, formats ...string) error {
+	if slices.Contains(formats, format) {
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q (use %s)", format, strings.Join(formats, ", "))
+}
+
+// jsonHost is the JSON representation of a discovered host.
+// Durations are encoded as Go duration strings (e.g. "1.5ms").
+type jsonHost struct {
+	IP            string        `json:"ip"`
+	MAC           string        `json:"mac,omitempty"`
+	Hostname      string        `json:"hostname,omitempty"`
+	RequestedName string        `json:"requested_name,omitempty"`
+	Manufacturer  string        `json:"manufacturer,omitempty"`
+	VendorReason  string        `json:"vendor_reason,omitempty"`
+	VendorDetails *OUIRecord    `json:"vendor_details,omitempty"`
+	Virtual       string        `json:"virtual,omitempty"`
+	OpenPorts     []int         `json:"open_ports,omitempty"`
+	OpenUDPPorts  []int         `json:"open_udp_ports,omitempty"`
+	TCPPorts      []jsonTCPPort `json:"tcp_ports,omitempty"`
+	UDPPorts      []jsonUDPPort `json:"udp_ports,omitempty"`
+	TCPTime       string        `json:"tcp_time,omitempty"`
+	ICMPTime      string        `json:"icmp_time,omitempty"`
+	Jitter        string        `json:"jitter,omitempty"`
+	ICMPTries     int           `json:"icmp_tries,omitempty"`
+	ResponderIP   string        `json:"responder_ip,omitempty"`
+	ProcessTime   string        `json:"process_time"`
+	Notes         []string      `json:"notes,omitempty"`
+	Label         string        `json:"label,omitempty"`
+	Interface     string        `json:"interface,omitempty"`
+	LLDPName      string        `json:"lldp_name,omitempty"`
+	LLDPPort      string        `json:"lldp_port,omitempty"`
+	Seen          int           `json:"seen,omitempty"`
+	FirstSeen     string        `json:"first_seen,omitempty"`
+	LastSeen      string        `json:"last_seen,omitempty"`
+	Offline       bool          `json:"offline,omitempty"`
+	Down          bool          `json:"down,omitempty"`
+}
+
+// jsonTCPPort is the JSON representation of an open TCP port and its handshake time
+type jsonTCPPort struct {
+	Port int    `json:"port"`
+	RTT  string `json:"rtt"`
+}
+
+// jsonUDPPort is the JSON representation of a probed UDP port and its state
+type jsonUDPPort struct {
+	Port  int    `json:"port"`
+	State string `json:"state"`
+	RTT   string `json:"rtt,omitempty"`
+}
+
+// VendorInfo is the manufacturer found for a MAC address by the vendor command
+type VendorInfo struct {
+	MAC          string     `json:"mac"`
+	Manufacturer string     `json:"manufacturer"`
+	Reason       string     `json:"reason,omitempty"`  // Why the manufacturer is unknown
+	Details      *OUIRecord `json:"details,omitempty"` // Full OUI entry, with -oui-full
+}
+
+// jsonProbes is the JSON representation of the probes sent during a scan
+type jsonProbes struct {
+	ICMP  uint64 `json:"icmp"`
+	TCP   uint64 `json:"tcp"`
+	UDP   uint64 `json:"udp"`
+	Bytes uint64 `json:"bytes"`
+
+	Errors uint64 `json:"errors,omitempty"`
+	Failed uint64 `json:"failed,omitempty"`
+}
+
+// jsonMeta is the JSON representation of the scan metadata
+type jsonMeta struct {
+	Targets     []string  `json:"targets"`
+	Started     string    `json:"started"`
+	Finished    string    `json:"finished"`
+	Version     string    `json:"version"`
+	Host        string    `json:"host,omitempty"`
+	Concurrency int       `json:"concurrency,omitempty"`
+	Timeout     string    `json:"timeout"`
+	Probes      []string  `json:"probes"`
+	Self        *jsonSelf `json:"self,omitempty"`
+}
+
+// jsonSelf is the JSON representation of the scanning host's network context
+type jsonSelf struct {
+	IP        string `json:"ip,omitempty"`
+	MAC       string `json:"mac,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Subnet    string `json:"subnet,omitempty"`
+	Gateway   string `json:"gateway,omitempty"`
+}
+
+// newJSONMeta converts scan metadata into its JSON representation
+func newJSONMeta(m *ScanMeta, macFormat string) *jsonMeta {
+	if m == nil {
+		return nil
+	}
+	return &jsonMeta{
+		Targets:     m.Targets,
+		Started:     m.Started.Format(time.RFC3339),
+		Finished:    m.Finished.Format(time.RFC3339),
+		Version:     m.Version,
+		Host:        m.Host,
+		Concurrency: m.Concurrency,
+		Timeout:     m.Timeout.String(),
+		Probes:      m.Probes,
+		Self:        (*jsonSelf)(m.Self.withMACFormat(macFormat)),
+	}
+}
+
+// jsonResult is the JSON representation of a scan result
+type jsonResult struct {
+	Meta      *jsonMeta  `json:"meta,omitempty"`
+	Total     int        `json:"total"`
+	Completed int        `json:"completed"`
+	Filtered  int        `json:"filtered,omitempty"`
+	Dropped   int        `json:"dropped,omitempty"`
+	Runs      int        `json:"runs,omitempty"`
+	Probes    jsonProbes `json:"probes"`
+	Hosts     []jsonHost `json:"hosts"`
+
+	LatencyHistogram []latencyBucket `json:"latency_histogram,omitempty"`
+}
+
+// newJSONResult converts a scan result into its JSON representation
+func newJSONResult(result *ScanResult, showJitter, showTries bool, macFormat string) jsonResult {
+	out := jsonResult{
+		Meta:      newJSONMeta(result.Meta, macFormat),
+		Total:     result.Total,
+		Completed: result.Completed,
+		Filtered:  result.Filtered,
+		Dropped:   result.Dropped,
+		Runs:      result.Runs,
+		Probes:    jsonProbes(result.Probes),
+		Hosts:     make([]jsonHost, 0, len(result.ReachableHosts)),
+	}
+
+	for _, host := range result.ReachableHosts {
+		h := jsonHost{
+			IP:            host.IP,
+			MAC:           formatMAC(host.MAC, macFormat),
+			Hostname:      host.Hostname,
+			RequestedName: host.RequestedName,
+			Manufacturer:  mac2manufacturer(host.MAC),
+			VendorReason:  vendorReason(host.MAC),
+			VendorDetails: vendorDetails(host.MAC),
+			Virtual:       virtualLabel(host.MAC),
+			OpenPorts:     host.OpenPorts,
+			OpenUDPPorts:  host.OpenUDPPorts,
+			ProcessTime:   host.ProcessTime.String(),
+			ResponderIP:   host.ResponderIP,
+			Notes:         host.Notes,
+			Label:         host.Label,
+			Interface:     host.Interface,
+			LLDPName:      host.LLDPSystemName,
+			LLDPPort:      host.LLDPPort,
+			Seen:          host.SeenRuns,
+			Down:          !host.Up,
+		}
+		if host.ICMPResponseTime > 0 {
+			h.ICMPTime = host.ICMPResponseTime.String()
+		}
+		for _, port := range host.TCPPortResults {
+			h.TCPPorts = append(h.TCPPorts, jsonTCPPort{Port: port.Port, RTT: port.RTT.String()})
+		}
+		for _, port := range host.UDPResults {
+			p := jsonUDPPort{Port: port.Port, State: port.State}
+			if port.RTT > 0 {
+				p.RTT = port.RTT.String()
+			}
+			h.UDPPorts = append(h.UDPPorts, p)
+		}
+		if host.ICMPResponseTime == 0 && len(host.TCPPortResults) > 0 {
+			h.TCPTime = host.ResponseTime().String()
+		}
+		if showJitter && host.ICMPResponseTime > 0 {
+			h.Jitter = host.RTTJitter.String()
+		}
+		if showTries {
+			h.ICMPTries = host.ICMPTries
+		}
+		if !host.FirstSeen.IsZero() {
+			h.FirstSeen = host.FirstSeen.Format(time.RFC3339)
+			h.LastSeen = host.LastSeen.Format(time.RFC3339)
+			h.Offline = host.Offline
+		}
+		out.Hosts = append(out.Hosts, h)
+	}
+
+	return out
+}
+
+// writeJSON encodes v as a single line of JSON, or indented for reading when
+// pretty is set
+func writeJSON(w io.Writer, v any, pretty bool) error {
+	if !pretty {
+		return json.NewEncoder(w).Encode(v)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// runPing implements the "ping" subcommand, pinging one host like the classic
+// ping tool: a line per echo request, then a summary
+func runPing(ui *UI, args []string) {
+	scanner := NewScanner()
+
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	count := fs.Int("count", 0, "Stop after this many echo requests (default: until interrupted)")
+	fs.IntVar(count, "c", 0, "Shorthand for -count")
+	interval := fs.Duration("interval", time.Second, "Time between echo requests")
+	fs.DurationVar(interval, "i", time.Second, "Shorthand for -interval")
+	fs.DurationVar(&scanner.PingTimeout, "timeout", time.Second, "How long to wait for each reply")
+	fs.IntVar(&scanner.ProbeSize, "size", 0, "Echo request payload size in bytes (default 4)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		ui.ShowUsage(filepath.Base(os.Args[0]))
+		os.Exit(1)
+	}
+	if *count < 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-count must not be negative"))
+		os.Exit(1)
+	}
+	if *interval <= 0 || scanner.PingTimeout <= 0 {
+		ui.ShowError("Error parsing flags", fmt.Errorf("-interval and -timeout must be positive"))
+		os.Exit(1)
+	}
+
+	host := fs.Arg(0)
+	dst, ok := parseTargetIP(host)
+	if !ok {
+		addr, err := net.ResolveIPAddr("ip", host)
+		if err != nil {
+			ui.ShowError("Error resolving host", err)
+			os.Exit(1)
+		}
+		dst = addr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := scanner.ping(ctx, host, dst, *count, *interval); err != nil {
+		ui.ShowError("Error pinging host", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// ping sends echo requests to dst every interval until count have been sent
+// or ctx is done, printing each reply and a summary
+func (s *Scanner) ping(ctx context.Context, host string, dst *net.IPAddr, count int, interval time.Duration) error {
+	family := icmpV4
+	if dst.IP.To4() == nil {
+		family = icmpV6
+	}
+
+	conn, err := s.pinger.listen(family)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Printf("PING %s (%s): %d data bytes\n", host, dst, len(s.echoPayload()))
+
+	id := os.Getpid() & 0xffff
+	var rtts []time.Duration
+	sent := 0
+	for seq := 1; (count == 0 || seq <= count) && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		reply, _, ok, err := s.echo(ctx, conn, family, dst, id)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		sent++
+
+		if ok {
+			rtts = append(rtts, reply.RTT)
+			from := dst.String()
+			if reply.Responder != nil {
+				from = reply.Responder.String()
+			}
+			fmt.Printf("reply from %s: seq=%d time=%s\n", from, seq, reply.RTT.Round(time.Microsecond))
+		} else {
+			fmt.Printf("request timeout for seq=%d\n", seq)
+		}
+
+		if count != 0 && seq == count {
+			break
+		}
+		select {
+		case <-time.After(interval - time.Since(start)):
+		case <-ctx.Done():
+		}
+	}
+
+	fmt.Printf("\n--- %s ping statistics ---\n", host)
+	loss := 0.0
+	if sent > 0 {
+		loss = 100 * float64(sent-len(rtts)) / float64(sent)
+	}
+	fmt.Printf("%d requests sent, %d replies received, %.1f%% loss\n", sent, len(rtts), loss)
+	if len(rtts) > 0 {
+		fmt.Printf("rtt min/avg/max/jitter = %s/%s/%s/%s\n",
+			slices.Min(rtts).Round(time.Microsecond), averageDuration(rtts).Round(time.Microsecond),
+			slices.Max(rtts).Round(time.Microsecond), rttJitter(rtts).Round(time.Microsecond))
+	}
+	return nil
+}
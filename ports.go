@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default port lists probed by the TCP and UDP scans
+var (
+	defaultTCPPorts = []int{80, 443, 22, 21, 23, 25, 53, 135, 139, 445}
+	defaultUDPPorts = []int{53, 67, 68, 69, 123, 137, 138, 161, 500, 514}
+)
+
+// Port lists picked by defaultTCPPortsFor when no ports are given. A LAN scan
+// adds the Windows and printer services common on office networks; a single
+// host gets the 100 ports nmap finds open most often.
+var (
+	lanTCPPorts    = append(slices.Clone(defaultTCPPorts), 3389, 5900, 8080, 515, 631, 9100)
+	top100TCPPorts = []int{
+		7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111, 113, 119, 135,
+		139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465, 513, 514, 515, 543, 544, 548, 554, 587, 631,
+		646, 873, 990, 993, 995, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+		2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+		6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+	}
+)
+
+// lanScanMaxTargets is the largest scan defaultTCPPortsFor treats as a LAN
+const lanScanMaxTargets = 1024
+
+// defaultTCPPortsFor picks the TCP ports to probe when none are given, from
+// the number of targets and whether they are on a private network. It also
+// returns a short description of the choice.
+func defaultTCPPortsFor(ips []string) ([]int, string) {
+	if len(ips) == 1 {
+		return top100TCPPorts, "the top 100 ports, for a single host"
+	}
+	if len(ips) > lanScanMaxTargets {
+		return defaultTCPPorts, "common ports"
+	}
+	for _, ip := range ips {
+		addr, ok := parseTargetIP(ip)
+		if !ok || !(addr.IP.IsPrivate() || addr.IP.IsLinkLocalUnicast()) {
+			return defaultTCPPorts, "common ports"
+		}
+	}
+	return lanTCPPorts, "common LAN ports, with RDP, VNC and printing"
+}
+
+// servicePorts maps IANA service names, plus a few common aliases, to their
+// well-known TCP port
+var servicePorts = map[string]int{
+	"ftp-data":      20,
+	"ftp":           21,
+	"ssh":           22,
+	"telnet":        23,
+	"smtp":          25,
+	"domain":        53,
+	"dns":           53,
+	"http":          80,
+	"kerberos":      88,
+	"pop3":          110,
+	"sunrpc":        111,
+	"rpcbind":       111,
+	"epmap":         135,
+	"msrpc":         135,
+	"netbios-ssn":   139,
+	"imap":          143,
+	"ldap":          389,
+	"https":         443,
+	"microsoft-ds":  445,
+	"smb":           445,
+	"submissions":   465,
+	"smtps":         465,
+	"submission":    587,
+	"ipp":           631,
+	"ldaps":         636,
+	"rsync":         873,
+	"imaps":         993,
+	"pop3s":         995,
+	"ms-sql-s":      1433,
+	"mssql":         1433,
+	"mqtt":          1883,
+	"nfs":           2049,
+	"mysql":         3306,
+	"ms-wbt-server": 3389,
+	"rdp":           3389,
+	"sip":           5060,
+	"postgresql":    5432,
+	"postgres":      5432,
+	"vnc":           5900,
+	"redis":         6379,
+	"http-alt":      8080,
+	"https-alt":     8443,
+	"mongodb":       27017,
+}
+
+// parseServiceSpec parses a comma-separated list of service names such as
+// "ssh,http,smb" into their TCP ports, in the order given
+func parseServiceSpec(spec string) ([]int, error) {
+	var ports []int
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		port, ok := servicePorts[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown service %q (known: %s)", name, strings.Join(serviceNames(), ", "))
+		}
+		if !slices.Contains(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no services in %q", spec)
+	}
+	return ports, nil
+}
+
+// serviceNames returns the known service names in alphabetical order
+func serviceNames() []string {
+	names := make([]string, 0, len(servicePorts))
+	for name := range servicePorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// portsFile is a port list loaded with -ports-file
+type portsFile struct {
+	TCP      []int
+	UDP      []int
+	Services map[string]int // TCP service names, usable with -ports-from-services
+}
+
+// loadPortsFile reads a port list in the style of nmap-services: one
+// "name port/proto" entry per line, e.g. "ssh 22/tcp". Comma-separated
+// "name,port,proto" lines work too, and "#" starts a comment.
+func loadPortsFile(path string) (*portsFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pf := &portsFile{Services: make(map[string]int)}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+
+		// "port/proto" in a single field or as two fields
+		if len(fields) == 2 {
+			if port, proto, ok := strings.Cut(fields[1], "/"); ok {
+				fields = []string{fields[0], port, proto}
+			}
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected name, port and protocol", path, n)
+		}
+
+		name := strings.ToLower(fields[0])
+		port, err := parsePort(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+
+		switch strings.ToLower(fields[2]) {
+		case "tcp":
+			if !slices.Contains(pf.TCP, port) {
+				pf.TCP = append(pf.TCP, port)
+			}
+			if _, ok := pf.Services[name]; !ok {
+				pf.Services[name] = port
+			}
+		case "udp":
+			if !slices.Contains(pf.UDP, port) {
+				pf.UDP = append(pf.UDP, port)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown protocol %q (use tcp or udp)", path, n, fields[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(pf.TCP) == 0 && len(pf.UDP) == 0 {
+		return nil, fmt.Errorf("%s: no ports", path)
+	}
+	return pf, nil
+}
+
+// parsePortSpec parses a comma-separated list of ports and ranges such as
+// "22,80,8000-8100". Duplicates are dropped and the order of first
+// appearance is kept.
+func parsePortSpec(spec string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			low, high = part[:i], part[i+1:]
+		}
+
+		start, err := parsePort(low)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parsePort(high)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+
+		for port := start; port <= end; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+	return ports, nil
+}
+
+// parsePortTimeouts parses per-port timeouts such as "3389=3s,25=2s". The
+// ports on the left take the same syntax as -ports, so "8000-8010=2s" works.
+func parsePortTimeouts(spec string) (map[int]time.Duration, error) {
+	timeouts := make(map[int]time.Duration)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		ports, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid port timeout %q (want port=duration)", part)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout in %q", part)
+		}
+		list, err := parsePortSpec(ports)
+		if err != nil {
+			return nil, err
+		}
+		for _, port := range list {
+			timeouts[port] = timeout
+		}
+	}
+	return timeouts, nil
+}
+
+// parsePort parses a single port number in the range 1-65535
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// excludePorts returns ports without any of the ports in exclude
+func excludePorts(ports, exclude []int) []int {
+	if len(exclude) == 0 {
+		return ports
+	}
+
+	skip := make(map[int]bool, len(exclude))
+	for _, port := range exclude {
+		skip[port] = true
+	}
+
+	var kept []int
+	for _, port := range ports {
+		if !skip[port] {
+			kept = append(kept, port)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"time"
+)
+
+// Prober is a host discovery method. The built-in ICMP, TCP and UDP probers are
+// selected from the Scanner settings; library users can add their own through
+// Scanner.Probers.
+type Prober interface {
+	// Name identifies the discovery method (e.g. "icmp")
+	Name() string
+	// Probe reports whether ip answered and how long the answer took
+	Probe(ctx context.Context, ip string) (reachable bool, rtt time.Duration, err error)
+}
+
+// hostProbe collects what the probers learn about a single host
+type hostProbe struct {
+	ip         string
+	pinger     pinger // Opens the ICMP sockets of the host's scan
+	responsive bool   // Answered a liveness probe (ICMP echo or a custom prober)
+	ping       pingResult
+	tcpPorts   []TCPPortResult
+	tcpRefused bool // A TCP port was closed with a reset, counted as up with Scanner.TCPRefusedUp
+	udpResults []UDPPortResult
+	self       bool // One of the scanning host's own addresses, not probed
+
+	portsCapped bool // Port probing stopped at Scanner.MaxPortsPerHost open ports
+}
+
+// hostProber is implemented by the built-in probers, which record more than
+// reachability (ping statistics, open ports) about a host
+type hostProber interface {
+	probeHost(ctx context.Context, h *hostProbe)
+}
+
+// probers returns the discovery methods to run against every host, in order.
+// UDP runs last because it is gated on what the other probers found.
+func (s *Scanner) probers() []Prober {
+	var probers []Prober
+	if !s.SkipICMP {
+		probers = append(probers, icmpProber{s})
+	}
+	probers = append(probers, s.Probers...)
+	if s.UseTCP {
+		probers = append(probers, tcpProber{s})
+	}
+	if s.UseUDP {
+		probers = append(probers, udpProber{s})
+	}
+	return probers
+}
+
+// probeHost runs the probers against ip, pinging it over sockets from ping,
+// recovering from a panic in any of them so that one misbehaving prober
+// fails only this host instead of the whole scan. A failed host is counted
+// and reported as unreachable.
+func (s *Scanner) probeHost(ctx context.Context, ip string, ping pinger) (h *hostProbe) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.probes.failed.Add(1)
+			s.logger.Error("host probe panicked", "ip", ip, "panic", r, "stack", string(debug.Stack()))
+			h = &hostProbe{ip: ip}
+		}
+	}()
+	return s.runProbers(ctx, ip, ping)
+}
+
+// runProbers runs every configured prober against ip
+func (s *Scanner) runProbers(ctx context.Context, ip string, ping pinger) *hostProbe {
+	h := &hostProbe{ip: ip, pinger: ping}
+
+	for _, p := range s.probers() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if hp, ok := p.(hostProber); ok {
+			hp.probeHost(ctx, h)
+			continue
+		}
+
+		reachable, rtt, err := p.Probe(ctx, ip)
+		if err != nil {
+			s.logger.Warn("probe failed", "prober", p.Name(), "ip", ip, "error", err)
+			continue
+		}
+		if reachable {
+			s.logger.Debug("probe answered", "prober", p.Name(), "ip", ip, "rtt", rtt)
+			h.responsive = true
+		}
+	}
+
+	return h
+}
+
+// icmpProber discovers hosts with ICMP echo requests
+type icmpProber struct{ s *Scanner }
+
+func (p icmpProber) Name() string { return "icmp" }
+
+func (p icmpProber) Probe(ctx context.Context, ip string) (bool, time.Duration, error) {
+	result := p.s.pingIP(ctx, p.s.pinger, ip)
+	return result.Reachable, result.RTT, nil
+}
+
+// probeHostRetryDelay is how long a host whose ping failed to send waits
+// before its single retry, letting transient socket errors clear
+const probeHostRetryDelay = 100 * time.Millisecond
+
+// probeHost pings the host, retrying once after a short delay when the ping
+// could not be sent at all (e.g. EAGAIN under heavy concurrency). A clean
+// timeout is not retried here; -retry-icmp covers that. Neither is a missing
+// raw socket permission, which no retry fixes and which is not a probe error.
+func (p icmpProber) probeHost(ctx context.Context, h *hostProbe) {
+	h.ping = p.s.pingIP(ctx, h.pinger, h.ip)
+	if errors.Is(h.ping.Err, ErrNoRawSocket) {
+		return
+	}
+	if h.ping.Err != nil && ctx.Err() == nil {
+		p.s.logger.Debug("icmp probe error, retrying host", "ip", h.ip, "error", h.ping.Err)
+		select {
+		case <-time.After(probeHostRetryDelay):
+			h.ping = p.s.pingIP(ctx, h.pinger, h.ip)
+		case <-ctx.Done():
+		}
+	}
+	if h.ping.Err != nil {
+		p.s.probes.errors.Add(1)
+	}
+	if h.ping.Reachable {
+		h.responsive = true
+	}
+}
+
+// tcpProber discovers hosts by connecting to common TCP ports
+type tcpProber struct{ s *Scanner }
+
+func (p tcpProber) Name() string { return "tcp" }
+
+func (p tcpProber) Probe(ctx context.Context, ip string) (bool, time.Duration, error) {
+	ports, refused := p.s.getOpenPorts(ctx, ip, p.s.MaxPortsPerHost)
+	return len(ports) > 0 || (refused && p.s.TCPRefusedUp), 0, nil
+}
+
+func (p tcpProber) probeHost(ctx context.Context, h *hostProbe) {
+	h.tcpPorts, h.tcpRefused = p.s.getOpenPorts(ctx, h.ip, p.s.MaxPortsPerHost)
+	if h.tcpRefused && p.s.TCPRefusedUp {
+		h.responsive = true
+	}
+	if max := p.s.MaxPortsPerHost; max > 0 && len(h.tcpPorts) >= max {
+		h.portsCapped = true
+	}
+}
+
+// udpProber discovers hosts by probing common UDP services
+type udpProber struct{ s *Scanner }
+
+func (p udpProber) Name() string { return "udp" }
+
+func (p udpProber) Probe(ctx context.Context, ip string) (bool, time.Duration, error) {
+	return len(openUDPPorts(p.s.getUDPPorts(ctx, ip, p.s.MaxPortsPerHost))) > 0, 0, nil
+}
+
+// probeHost only runs UDP probes when the host has already shown some
+// responsiveness, unless Scanner.UDPAll is set. This avoids marking many UDP
+// ports as open|filtered for hosts that are likely down/unreachable.
+func (p udpProber) probeHost(ctx context.Context, h *hostProbe) {
+	if h.portsCapped || !(h.responsive || len(h.tcpPorts) > 0 || p.s.UDPAll) {
+		return
+	}
+
+	// Open TCP ports count towards the per-host limit
+	limit := 0
+	if max := p.s.MaxPortsPerHost; max > 0 {
+		limit = max - len(h.tcpPorts)
+	}
+	h.udpResults = p.s.getUDPPorts(ctx, h.ip, limit)
+	if limit > 0 && len(openUDPPorts(h.udpResults)) >= limit {
+		h.portsCapped = true
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// deniedPinger fails every listen the way an unprivileged raw socket does
+type deniedPinger struct{ listens int }
+
+func (p *deniedPinger) listen(family icmpFamily) (net.PacketConn, error) {
+	p.listens++
+	return nil, fmt.Errorf("%w: operation not permitted", ErrNoRawSocket)
+}
+
+func TestICMPProberNoRawSocket(t *testing.T) {
+	s := NewScanner()
+	p := &deniedPinger{}
+	s.pinger = p
+
+	h := &hostProbe{ip: "198.51.100.1", pinger: p}
+	start := time.Now()
+	icmpProber{s}.probeHost(context.Background(), h)
+
+	if !errors.Is(h.ping.Err, ErrNoRawSocket) {
+		t.Errorf("ping error = %v, want ErrNoRawSocket", h.ping.Err)
+	}
+	// A permission error is not transient, so the host is not retried
+	if p.listens != 1 || time.Since(start) >= probeHostRetryDelay {
+		t.Errorf("listened %d times in %s, want once without the retry delay", p.listens, time.Since(start))
+	}
+	if n := s.probes.snapshot().Errors; n != 0 {
+		t.Errorf("counted %d probe errors, want 0", n)
+	}
+}
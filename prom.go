@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// promFileName is the file -o writes Prometheus metrics to. node_exporter's
+// textfile collector reads every *.prom file in its directory, so the file is
+// replaced on each scan instead of named after the scan time.
+const promFileName = "neti.prom"
+
+// promEscaper escapes label values for the Prometheus text exposition format
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeProm renders the scan as metrics in the Prometheus text exposition
+// format: one neti_host_up series per host plus scan-wide gauges
+func writeProm(w io.Writer, result *ScanResult, macFormat string) error {
+	bw := bufio.NewWriter(w)
+
+	if result.Meta != nil {
+		fmt.Fprintf(bw, "# %s\n", result.Meta)
+		if result.Meta.Self != nil {
+			fmt.Fprintf(bw, "# %s\n", result.Meta.Self.withMACFormat(macFormat))
+		}
+	}
+	fmt.Fprintln(bw, "# HELP neti_host_up Whether the host answered the latest scan.")
+	fmt.Fprintln(bw, "# TYPE neti_host_up gauge")
+	for _, host := range result.ReachableHosts {
+		up := 0
+		if host.Up && !host.Offline {
+			up = 1
+		}
+		fmt.Fprintf(bw, "neti_host_up{%s} %d\n", promHostLabels(host, macFormat), up)
+	}
+
+	fmt.Fprintln(bw, "# HELP neti_host_response_seconds Response time of the host, from ICMP or else the fastest TCP handshake.")
+	fmt.Fprintln(bw, "# TYPE neti_host_response_seconds gauge")
+	for _, host := range result.ReachableHosts {
+		if rtt := host.ResponseTime(); host.Up && !host.Offline && rtt > 0 {
+			fmt.Fprintf(bw, "neti_host_response_seconds{ip=\"%s\"} %g\n", promEscaper.Replace(host.IP), rtt.Seconds())
+		}
+	}
+
+	gauges := []struct {
+		name, help string
+		value      int
+	}{
+		{"neti_hosts_up", "Hosts that answered the latest scan.", onlineHosts(result.ReachableHosts)},
+		{"neti_targets", "Addresses targeted by the scan.", result.Total},
+		{"neti_targets_probed", "Addresses probed before the scan finished or stopped.", result.Completed},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value)
+	}
+
+	fmt.Fprintln(bw, "# HELP neti_probes_sent Probes sent during the scan.")
+	fmt.Fprintln(bw, "# TYPE neti_probes_sent gauge")
+	fmt.Fprintf(bw, "neti_probes_sent{protocol=\"icmp\"} %d\n", result.Probes.ICMP)
+	fmt.Fprintf(bw, "neti_probes_sent{protocol=\"tcp\"} %d\n", result.Probes.TCP)
+	fmt.Fprintf(bw, "neti_probes_sent{protocol=\"udp\"} %d\n", result.Probes.UDP)
+
+	return bw.Flush()
+}
+
+// promHostLabels returns the label set identifying a host, leaving out labels
+// with no value
+func promHostLabels(host HostInfo, macFormat string) string {
+	labels := [][2]string{
+		{"ip", host.IP},
+		{"mac", formatMAC(host.MAC, macFormat)},
+		{"vendor", mac2manufacturer(host.MAC)},
+		{"hostname", host.Hostname},
+	}
+
+	var parts []string
+	for _, l := range labels {
+		if l[1] != "" {
+			parts = append(parts, fmt.Sprintf("%s=\"%s\"", l[0], promEscaper.Replace(l[1])))
+		}
+	}
+	return strings.Join(parts, ",")
+}
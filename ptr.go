@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ptrConcurrency is how many reverse lookups the BatchPTR stage runs at once
+const ptrConcurrency = 32
+
+// resolveHostnames looks up the names of the hosts whose IP is in pending,
+// after every target was probed, through a pool of ptrConcurrency lookups.
+// Progress is reported to progressCallback, with found counting the hosts
+// that got a name.
+func (s *Scanner) resolveHostnames(ctx context.Context, hosts []HostInfo, pending map[string]bool, progressCallback ProgressCallback) {
+	var indexes []int
+	for i := range hosts {
+		if pending[hosts[i].IP] {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return
+	}
+	if s.ResolveStarted != nil {
+		s.ResolveStarted(len(indexes))
+	}
+	s.logger.Debug("resolving hostnames", "hosts", len(indexes))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var completed, named int
+	semaphore := make(chan struct{}, ptrConcurrency)
+	for _, i := range indexes {
+		wg.Add(1)
+		go func(host *HostInfo) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-semaphore }()
+
+			// Each goroutine writes only its own host
+			host.Hostname = s.lookupHostname(ctx, host.IP)
+
+			mu.Lock()
+			completed++
+			if host.Hostname != "" {
+				named++
+			}
+			if progressCallback != nil {
+				progressCallback(completed, len(indexes), named)
+			}
+			mu.Unlock()
+		}(&hosts[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isConnRefused reports whether a dial failed because the host reset the
+// connection, which proves it is up even though the port is closed. On a UDP
+// socket it means an ICMP port unreachable came back.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
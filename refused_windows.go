@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isConnRefused reports whether a dial failed because the host reset the
+// connection, which proves it is up even though the port is closed. Windows
+// reports an ICMP port unreachable on a UDP socket as a reset.
+func isConnRefused(err error) bool {
+	return errors.Is(err, windows.WSAECONNREFUSED) || errors.Is(err, windows.WSAECONNRESET)
+}
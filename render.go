@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// loadResults reads scan results saved with -format json. A file may hold one
+// result or several, one per line, as written by -watch.
+func loadResults(path string) ([]*ScanResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var results []*ScanResult
+	decoder := json.NewDecoder(file)
+	for {
+		var saved jsonResult
+		if err := decoder.Decode(&saved); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		result, err := saved.scanResult()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s holds no scan results", path)
+	}
+	return results, nil
+}
+
+// scanMeta converts saved JSON metadata back into scan metadata
+func (m jsonMeta) scanMeta() (*ScanMeta, error) {
+	meta := &ScanMeta{
+		Targets:     m.Targets,
+		Version:     m.Version,
+		Host:        m.Host,
+		Concurrency: m.Concurrency,
+		Probes:      m.Probes,
+		Self:        (*SelfContext)(m.Self),
+	}
+
+	var err error
+	if meta.Started, err = time.Parse(time.RFC3339, m.Started); err != nil {
+		return nil, err
+	}
+	if meta.Finished, err = time.Parse(time.RFC3339, m.Finished); err != nil {
+		return nil, err
+	}
+	if meta.Timeout, err = parseOptionalDuration(m.Timeout); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// scanResult converts a saved JSON result back into a scan result
+func (r jsonResult) scanResult() (*ScanResult, error) {
+	result := &ScanResult{
+		Total:     r.Total,
+		Completed: r.Completed,
+		Filtered:  r.Filtered,
+		Dropped:   r.Dropped,
+		Runs:      r.Runs,
+		Probes:    ProbeStats(r.Probes),
+	}
+	if r.Meta != nil {
+		meta, err := r.Meta.scanMeta()
+		if err != nil {
+			return nil, fmt.Errorf("meta: %w", err)
+		}
+		result.Meta = meta
+	}
+
+	for _, h := range r.Hosts {
+		host, err := h.hostInfo()
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.IP, err)
+		}
+		result.ReachableHosts = append(result.ReachableHosts, host)
+	}
+	return result, nil
+}
+
+// hostInfo converts a saved JSON host back into a host, parsing its durations
+// and restoring the MAC to the colon-separated form used internally
+func (h jsonHost) hostInfo() (HostInfo, error) {
+	host := HostInfo{
+		IP:             h.IP,
+		Up:             !h.Down,
+		MAC:            formatMAC(h.MAC, MACFormatColon),
+		Hostname:       h.Hostname,
+		RequestedName:  h.RequestedName,
+		Label:          h.Label,
+		Interface:      h.Interface,
+		LLDPSystemName: h.LLDPName,
+		LLDPPort:       h.LLDPPort,
+		SeenRuns:       h.Seen,
+		OpenPorts:      h.OpenPorts,
+		OpenUDPPorts:   h.OpenUDPPorts,
+		ICMPTries:      h.ICMPTries,
+		ResponderIP:    h.ResponderIP,
+		Notes:          h.Notes,
+		Offline:        h.Offline,
+	}
+
+	var err error
+	if host.ProcessTime, err = parseOptionalDuration(h.ProcessTime); err != nil {
+		return host, err
+	}
+	if host.ICMPResponseTime, err = parseOptionalDuration(h.ICMPTime); err != nil {
+		return host, err
+	}
+	if host.RTTJitter, err = parseOptionalDuration(h.Jitter); err != nil {
+		return host, err
+	}
+	for _, port := range h.TCPPorts {
+		rtt, err := time.ParseDuration(port.RTT)
+		if err != nil {
+			return host, err
+		}
+		host.TCPPortResults = append(host.TCPPortResults, TCPPortResult{Port: port.Port, RTT: rtt})
+	}
+	for _, port := range h.UDPPorts {
+		rtt, err := parseOptionalDuration(port.RTT)
+		if err != nil {
+			return host, err
+		}
+		host.UDPResults = append(host.UDPResults, UDPPortResult{Port: port.Port, State: port.State, RTT: rtt})
+	}
+	if host.FirstSeen, err = parseOptionalTime(h.FirstSeen); err != nil {
+		return host, err
+	}
+	if host.LastSeen, err = parseOptionalTime(h.LastSeen); err != nil {
+		return host, err
+	}
+	return host, nil
+}
+
+// parseOptionalDuration parses a Go duration string; an empty string is zero
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseOptionalTime parses an RFC 3339 timestamp; an empty string is the zero time
+func parseOptionalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+// repeatScan runs scan n times back to back and merges the results, counting
+// in how many runs each host answered. Hosts that answer only some of the
+// time show up with a lower count.
+func repeatScan(ctx context.Context, ui *UI, n int, scan func(context.Context) *ScanResult) *ScanResult {
+	merged := &ScanResult{}
+	index := make(map[string]int)
+
+	for run := 1; run <= n && ctx.Err() == nil; run++ {
+		ui.ShowStatus("(Run %d of %d)", run, n)
+		result := scan(ctx)
+		ui.stopProgress()
+
+		merged.Runs = run
+		merged.Total = result.Total
+		merged.Completed = result.Completed
+		merged.Probes = merged.Probes.add(result.Probes)
+
+		for _, host := range result.ReachableHosts {
+			i, ok := index[host.IP]
+			if !ok {
+				i = len(merged.ReachableHosts)
+				index[host.IP] = i
+				merged.ReachableHosts = append(merged.ReachableHosts, host)
+			}
+
+			// Keep the latest reply, and a down entry only until the host answers
+			seen := merged.ReachableHosts[i].SeenRuns
+			if host.Up {
+				seen++
+			}
+			if host.Up || !merged.ReachableHosts[i].Up {
+				merged.ReachableHosts[i] = host
+			}
+			merged.ReachableHosts[i].SeenRuns = seen
+		}
+	}
+
+	sort.Slice(merged.ReachableHosts, func(i, j int) bool {
+		return lessIP(merged.ReachableHosts[i].IP, merged.ReachableHosts[j].IP)
+	})
+	return merged
+}
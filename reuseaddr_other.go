@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// setReuseAddr is not implemented on this platform
+func setReuseAddr(c syscall.RawConn) error {
+	return fmt.Errorf("sharing a source port between probes is not supported on %s", runtime.GOOS)
+}
+
+// tuneUDPProbeSocket leaves the socket options at their defaults on this
+// platform
+func tuneUDPProbeSocket(c syscall.RawConn) error {
+	return nil
+}
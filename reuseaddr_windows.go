@@ -0,0 +1,33 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// setReuseAddr lets several probe sockets bind the same local port at once
+func setReuseAddr(c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// tuneUDPProbeSocket sets up a per-port UDP probe socket for dense scans: a
+// small receive buffer since only one short reply is read, and a zero linger
+// so close releases the socket without waiting. SO_REUSEADDR is left off, as
+// on Windows it would let the socket take over a port another one is bound
+// to. The options are best effort; a probe works without them.
+func tuneUDPProbeSocket(c syscall.RawConn) error {
+	return c.Control(func(fd uintptr) {
+		windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_RCVBUF, udpProbeRecvBuffer)
+		windows.SetsockoptLinger(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_LINGER, &windows.Linger{Onoff: 1, Linger: 0})
+	})
+}
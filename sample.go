@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+)
+
+// targetSpace is the address space of one requested subnet, sampled without
+// expanding it
+type targetSpace struct {
+	subnet string
+	size   *big.Int
+	at     func(offset *big.Int) string
+}
+
+// newTargetSpace returns the address space of a subnet or hostname target
+func newTargetSpace(scanner *Scanner, subnet string) (targetSpace, error) {
+	if isHostnameTarget(subnet) {
+		ips, err := scanner.GetIPsFromSubnet(subnet)
+		if err != nil {
+			return targetSpace{}, err
+		}
+		return targetSpace{subnet: subnet, size: big.NewInt(int64(len(ips))), at: func(offset *big.Int) string {
+			return ips[offset.Int64()]
+		}}, nil
+	}
+
+	it, err := newIPIterator(subnet, scanner.IncludeNetworkBroadcast)
+	if err != nil {
+		return targetSpace{}, err
+	}
+	return targetSpace{subnet: subnet, size: it.Len(), at: func(offset *big.Int) string {
+		return withZone(it.At(offset).String(), it.Zone())
+	}}, nil
+}
+
+// sampleTargets picks n distinct addresses at random from the subnets, using
+// rng, and returns them grouped by subnet in address order together with the
+// size of the whole target space. Even /8 or IPv6 /64 networks are sampled
+// without listing their addresses. When n covers the whole space every
+// address is returned.
+func sampleTargets(scanner *Scanner, subnets []string, n int, rng *rand.Rand) ([]subnetTargets, *big.Int, error) {
+	var spaces []targetSpace
+	total := new(big.Int)
+	for _, subnet := range subnets {
+		space, err := newTargetSpace(scanner, subnet)
+		if err != nil {
+			return nil, nil, err
+		}
+		spaces = append(spaces, space)
+		total.Add(total, space.size)
+	}
+
+	if total.Cmp(big.NewInt(int64(n))) <= 0 {
+		groups, err := collectTargets(scanner, subnets)
+		return groups, total, err
+	}
+
+	// Draw distinct offsets into the combined space
+	picked := make(map[string]*big.Int, n)
+	for len(picked) < n {
+		offset := new(big.Int).Rand(rng, total)
+		picked[offset.String()] = offset
+	}
+	offsets := make([]*big.Int, 0, n)
+	for _, offset := range picked {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i].Cmp(offsets[j]) < 0 })
+
+	// Offsets are sorted, so they are mapped onto the subnets in one pass
+	groups := make([]subnetTargets, len(spaces))
+	seen := make(map[string]bool, n)
+	start, i := new(big.Int), 0
+	for _, offset := range offsets {
+		for new(big.Int).Sub(offset, start).Cmp(spaces[i].size) >= 0 {
+			start.Add(start, spaces[i].size)
+			i++
+		}
+
+		ip := spaces[i].at(new(big.Int).Sub(offset, start))
+		if !seen[ip] {
+			seen[ip] = true
+			groups[i].IPs = append(groups[i].IPs, ip)
+		}
+	}
+	for i := range groups {
+		groups[i].Subnet = spaces[i].subnet
+	}
+
+	return groups, total, nil
+}
+
+// estimateHosts scales the hosts found in a sample of sampled addresses up to
+// a space of total addresses
+func estimateHosts(found, sampled int, total *big.Int) *big.Int {
+	if sampled == 0 {
+		return new(big.Int)
+	}
+	estimate := new(big.Int).Mul(total, big.NewInt(int64(found)))
+	return estimate.Div(estimate, big.NewInt(int64(sampled)))
+}
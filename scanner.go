@@ -1,29 +1,103 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand"
 	"net"
 	"os"
+	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"neti/macaddr"
 
 	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 // HostInfo represents information about a discovered host
 type HostInfo struct {
 	IP               string
+	Up               bool // False for unreachable IPs, only recorded with Scanner.RecordDown
 	MAC              string
-	Hostname         string
-	ProcessTime      time.Duration // Total processing time (DNS, MAC, etc.)
-	ICMPResponseTime time.Duration // ICMP ping response time
-	OpenPorts        []int         // Discovered open ports
+	Hostname         string          // Reverse DNS (PTR) name
+	RequestedName    string          // Name the target was given as, when it was resolved from a hostname
+	Label            string          // Friendly name from a -labels file
+	Interface        string          // Local interface the host was reached on, with -all-interfaces
+	ProcessTime      time.Duration   // Total processing time (DNS, MAC, etc.)
+	ICMPResponseTime time.Duration   // ICMP ping response time (average when several echoes are sent)
+	RTTJitter        time.Duration   // Mean deviation between consecutive ICMP round-trip times
+	ICMPTries        int             // Echo requests sent, including retries
+	ResponderIP      string          // Address that answered the ping when it was not IP
+	OpenPorts        []int           // Discovered open TCP ports
+	TCPPortResults   []TCPPortResult // Handshake time of each open TCP port
+	OpenUDPPorts     []int           // UDP ports that answered a probe
+	UDPResults       []UDPPortResult // State of every UDP port probed
+	Notes            []string        // Annotations added after the scan (e.g. baseline changes)
+	LLDPSystemName   string          // System name the host advertised over LLDP
+	LLDPPort         string          // Port the host advertised over LLDP
+	SeenRuns         int             // Runs the host answered in, when the scan was repeated
+	Seq              int             // Order the host answered in during its scan, from 1; 0 for down hosts
+
+	// Set in watch mode, where hosts are remembered across scans
+	FirstSeen time.Time // Start of the first scan that found the host
+	LastSeen  time.Time // Start of the latest scan that found the host
+	Offline   bool      // Seen in a recent scan but not in the latest one
+}
+
+// TCPPortResult is an open TCP port and how long its connection took to establish
+type TCPPortResult struct {
+	Port int
+	RTT  time.Duration
+}
+
+// UDP port states, as classified by nmap
+const (
+	UDPOpen         = "open"          // The service replied
+	UDPClosed       = "closed"        // The host answered with ICMP port unreachable
+	UDPOpenFiltered = "open|filtered" // No answer: a silent service or a firewall
+)
+
+// UDPPortResult is the state of a probed UDP port. RTT is set for open ports.
+type UDPPortResult struct {
+	Port  int
+	State string
+	RTT   time.Duration
+}
+
+// openUDPPorts returns the port numbers of open UDP port results
+func openUDPPorts(results []UDPPortResult) []int {
+	var ports []int
+	for _, result := range results {
+		if result.State == UDPOpen {
+			ports = append(ports, result.Port)
+		}
+	}
+	return ports
+}
+
+// ResponseTime returns the host's ICMP response time, or for hosts that did not
+// answer ICMP the fastest TCP handshake, the only latency seen for them
+func (h HostInfo) ResponseTime() time.Duration {
+	if h.ICMPResponseTime > 0 {
+		return h.ICMPResponseTime
+	}
+
+	var fastest time.Duration
+	for _, port := range h.TCPPortResults {
+		if fastest == 0 || port.RTT < fastest {
+			fastest = port.RTT
+		}
+	}
+	return fastest
 }
 
 // ScanResult represents the result of scanning a subnet
@@ -31,111 +105,386 @@ type ScanResult struct {
 	ReachableHosts []HostInfo
 	Total          int
 	Completed      int
+	Filtered       int // Reachable hosts removed by result filters
+	Dropped        int // Reachable hosts left out of a random sample kept by Scanner.MaxResults
+	Runs           int // Times the scan was run with -repeat, 0 for a single run
+	Probes         ProbeStats
+	Meta           *ScanMeta // How the scan was run; nil for library scans
+}
+
+// ProbeStats counts the probes sent during a scan and the approximate bytes
+// they put on the wire (including IP and transport headers)
+type ProbeStats struct {
+	ICMP  uint64
+	TCP   uint64
+	UDP   uint64
+	Bytes uint64
+
+	// Errors counts hosts that could not be pinged at all (socket or send
+	// failures, even after a retry), as opposed to hosts that stayed silent
+	Errors uint64
+
+	// Failed counts hosts whose probing panicked and was abandoned
+	Failed uint64
+}
+
+// add returns the sum of two sets of probe counts
+func (p ProbeStats) add(q ProbeStats) ProbeStats {
+	return ProbeStats{
+		ICMP:   p.ICMP + q.ICMP,
+		TCP:    p.TCP + q.TCP,
+		UDP:    p.UDP + q.UDP,
+		Bytes:  p.Bytes + q.Bytes,
+		Errors: p.Errors + q.Errors,
+		Failed: p.Failed + q.Failed,
+	}
+}
+
+// Approximate on-the-wire sizes used for probe accounting
+const (
+	ipv4HeaderSize   = 20
+	ipv6HeaderSize   = 40
+	udpHeaderSize    = 8
+	tcpSYNSize       = ipv4HeaderSize + 40 // SYN with typical options
+	tcpHandshakeSize = ipv4HeaderSize + 32 // ACK or FIN/RST after a completed connect
+)
+
+// probeCounters accumulates ProbeStats from concurrent host goroutines
+type probeCounters struct {
+	icmp   atomic.Uint64
+	tcp    atomic.Uint64
+	udp    atomic.Uint64
+	bytes  atomic.Uint64
+	errors atomic.Uint64
+	failed atomic.Uint64
+}
+
+// snapshot returns the current counter values
+func (c *probeCounters) snapshot() ProbeStats {
+	return ProbeStats{
+		ICMP:   c.icmp.Load(),
+		TCP:    c.tcp.Load(),
+		UDP:    c.udp.Load(),
+		Bytes:  c.bytes.Load(),
+		Errors: c.errors.Load(),
+		Failed: c.failed.Load(),
+	}
+}
+
+// since returns the probes counted after the earlier snapshot
+func (p ProbeStats) since(earlier ProbeStats) ProbeStats {
+	return ProbeStats{
+		ICMP:   p.ICMP - earlier.ICMP,
+		TCP:    p.TCP - earlier.TCP,
+		UDP:    p.UDP - earlier.UDP,
+		Bytes:  p.Bytes - earlier.Bytes,
+		Errors: p.Errors - earlier.Errors,
+		Failed: p.Failed - earlier.Failed,
+	}
 }
 
 // ProgressCallback is called during scanning to report progress
 type ProgressCallback func(completed, total, found int)
 
+// HostFoundCallback is called for each reachable host as soon as it is discovered.
+// Calls are serialized with each other and with the ProgressCallback.
+type HostFoundCallback func(host HostInfo)
+
 // Scanner handles network scanning operations
 type Scanner struct {
-	Concurrency int
-	Timeout     time.Duration
-	macResolver *macaddr.Resolver
-	UseTCP      bool
-	UseUDP      bool
+	Concurrency    int           // Hosts probed at once; 0 picks a value with autoConcurrency
+	PingTimeout    time.Duration // Wait for each ICMP echo reply
+	TCPTimeout     time.Duration // Wait for each TCP connect
+	UDPTimeout     time.Duration // Wait for each UDP probe reply
+	ResolveTimeout time.Duration // Wait for each reverse DNS lookup
+	Count          int           // Number of ICMP echo requests sent to each host
+	ICMPRetries    int           // Extra echo requests sent when a reply is late, within the same timeout
+
+	// DetectForeignReplies accepts echo replies that match our request but come
+	// from a different address, recording the responder as an anomaly
+	DetectForeignReplies bool
+
+	// ProbeSize is the ICMP echo payload size in bytes; 0 sends a 4-byte payload.
+	// With DontFragment set, large probes find where the path MTU is too small.
+	ProbeSize    int
+	DontFragment bool
+
+	// ICMPFallback sends ICMP timestamp and address mask requests to hosts
+	// that ignore echo, since some hardened hosts still answer those
+	ICMPFallback bool
+
+	// IncludeNetworkBroadcast keeps the network and broadcast addresses (and
+	// the IPv6 subnet-router anycast address) that subnets are normally
+	// trimmed of, for networks that assign them to hosts
+	IncludeNetworkBroadcast bool
+
+	macResolver       *macaddr.Resolver
+	UseTCP            bool
+	UseUDP            bool
+	UDPAll            bool                  // Probe UDP on every host, not only those that already responded
+	TCPRefusedUp      bool                  // Count a refused TCP connection (RST) as proof the host is up
+	TCPPorts          []int                 // Ports probed by the TCP connect scan
+	UDPPorts          []int                 // Ports probed by the UDP scan
+	UDPConcurrency    int                   // UDP ports of one host probed at once; 0 uses defaultUDPHostConcurrency
+	UDPSingleSocket   bool                  // Probe all UDP ports of a host from one socket; closed ports then read as open|filtered
+	MaxPortsPerHost   int                   // Stop probing a host's ports once this many TCP and UDP ports are open; 0 for no limit
+	SourcePort        int                   // Local port TCP and UDP probes are sent from; 0 picks an ephemeral port
+	SourceIP          net.IP                // Local address all probes are sent from; nil lets the route decide
+	ICMPPerHostSocket bool                  // Open an ICMP socket for every host instead of sharing one per scan
+	SkipICMP          bool                  // Find hosts by the other probers alone, for scans where ICMP cannot reach the targets
+	FirstOnly         bool                  // Stop the scan as soon as the first reachable host is found
+	StopAfterFound    int                   // Stop the scan once this many reachable hosts are found; 0 scans every target
+	MaxResults        int                   // Keep a random sample of at most this many reachable hosts; 0 keeps all
+	PortTimeouts      map[int]time.Duration // TCP connect timeout of slow ports, overriding TCPTimeout
+	RecordDown        bool                  // Also return the IPs that did not answer, with Up false
+	IncludeSelf       bool                  // Probe the scanning host's own addresses instead of listing them unprobed
+	Probers           []Prober              // Additional discovery methods run after the ICMP probe
+	HostFound         HostFoundCallback     // Optional; called for each reachable host as it is found
+	BatchPTR          bool                  // Resolve hostnames in a stage after every target is probed instead of as each host is found
+	ResolveStarted    func(total int)       // Optional; called before the BatchPTR stage, whose progress then goes to the ProgressCallback
+	echoSeq           atomic.Uint32         // Source of unique ICMP sequence numbers
+	dialer            dialer                // Opens TCP connections; nil uses a net.Dialer with TCPTimeout
+	pinger            pinger                // Opens the ICMP echo socket
+	resolver          resolver              // Looks up hostnames; nil uses net.DefaultResolver
+	dnsLeaks          *dnsLeaks             // Reverse lookups of private addresses sent to a public server; nil skips the check
+	targetNames       map[string]string     // Hostname each target IP was resolved from
+	probes            probeCounters         // Probes sent by the current scan
+	logger            *slog.Logger
+}
+
+// maxICMPRetries bounds ICMPRetries so the retry windows stay usefully long
+const maxICMPRetries = 5
+
+// pingResult holds the outcome of pinging a single host
+type pingResult struct {
+	Reachable bool
+	RTT       time.Duration // Average round-trip time of the received replies
+	Jitter    time.Duration // Mean deviation between consecutive round-trip times
+	Sent      int           // Echo requests sent, including retries
+	Received  int
+	Responder string // Source of a reply that did not come from the target, if any
+	Fallback  string // ICMP query type that answered when echo did not, if any
+	Err       error  // Why the host could not be pinged; nil when it was probed, answered or not
+}
+
+// defaultTimeout is the per-probe timeout used for every phase unless overridden
+const defaultTimeout = 500 * time.Millisecond
+
+// maxProbeSize is the largest echo payload that fits in an IPv4 packet
+const maxProbeSize = 65535 - ipv4HeaderSize - 8
+
+// Bounds for the automatically chosen concurrency
+const (
+	concurrencyPerCPU  = 32
+	minAutoConcurrency = 16
+	maxAutoConcurrency = 256
+)
+
+// autoConcurrency returns how many hosts to probe at once when none was set.
+// Probes mostly wait on the network, so it allows concurrencyPerCPU hosts per
+// CPU, kept between minAutoConcurrency and maxAutoConcurrency so a weak machine
+// is not flooded, and never more than the number of targets.
+func autoConcurrency(cpus, targets int) int {
+	n := min(max(cpus*concurrencyPerCPU, minAutoConcurrency), maxAutoConcurrency)
+	return max(min(n, targets), 1)
+}
+
+// defaultResolveTimeout bounds each reverse DNS lookup
+const defaultResolveTimeout = time.Second
+
+// ScannerOption configures a Scanner created by NewScanner
+type ScannerOption func(*Scanner)
+
+// WithLogger makes the scanner log per-probe events, retries and errors to
+// logger instead of discarding them
+func WithLogger(logger *slog.Logger) ScannerOption {
+	return func(s *Scanner) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
 }
 
 // NewScanner creates a new scanner with default settings
-func NewScanner() *Scanner {
-	return &Scanner{
-		Concurrency: 20,
-		Timeout:     500 * time.Millisecond,
-		macResolver: macaddr.NewResolver(),
+func NewScanner(opts ...ScannerOption) *Scanner {
+	s := &Scanner{
+		PingTimeout:    defaultTimeout,
+		TCPTimeout:     defaultTimeout,
+		UDPTimeout:     defaultTimeout,
+		ResolveTimeout: defaultResolveTimeout,
+		Count:          1,
+		TCPPorts:       defaultTCPPorts,
+		UDPPorts:       defaultUDPPorts,
+		macResolver:    macaddr.NewResolver(),
+		logger:         slog.New(slog.DiscardHandler),
+	}
+	s.pinger = rawPinger{s}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-// GetIPsFromSubnet converts a CIDR subnet to a list of IP addresses
+// GetIPsFromSubnet converts a CIDR subnet, single address or hostname to a list
+// of IP addresses. IPv6 targets may carry a zone (e.g. fe80::1%eth0), which is
+// kept on every address.
 func (s *Scanner) GetIPsFromSubnet(subnet string) ([]string, error) {
-	_, ipNet, err := net.ParseCIDR(subnet)
+	if isHostnameTarget(subnet) {
+		return s.resolveTargetName(subnet)
+	}
+
+	it, err := newIPIterator(subnet, s.IncludeNetworkBroadcast)
 	if err != nil {
 		return nil, err
 	}
 
 	var ips []string
-	for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); incrementIP(ip) {
-		ips = append(ips, ip.String())
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		ips = append(ips, withZone(ip.String(), it.Zone()))
 	}
+	return ips, nil
+}
 
-	// Remove network and broadcast addresses for /24 and smaller subnets
-	if len(ips) > 2 {
-		ips = ips[1 : len(ips)-1]
+// resolveTargetName looks up every address of a hostname target and remembers
+// the name so results can show it next to the reverse DNS name
+func (s *Scanner) resolveTargetName(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.ResolveTimeout)
+	defer cancel()
+
+	addrs, err := s.lookupResolver().LookupNetIP(ctx, "ip", name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSubnet, err)
 	}
 
+	if s.targetNames == nil {
+		s.targetNames = make(map[string]string)
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ip := addr.Unmap().String()
+		if _, ok := s.targetNames[ip]; !ok {
+			s.targetNames[ip] = name
+		}
+		ips = append(ips, ip)
+	}
 	return ips, nil
 }
 
+// keepSample adds host, the reached-th reachable host, to hosts. Past
+// MaxResults it keeps a uniform random sample instead (reservoir sampling),
+// so a target that answers for every address cannot grow the results without
+// bound.
+func (s *Scanner) keepSample(hosts []HostInfo, host HostInfo, reached int) []HostInfo {
+	if s.MaxResults <= 0 || len(hosts) < s.MaxResults {
+		return append(hosts, host)
+	}
+	if i := rand.Intn(reached); i < s.MaxResults {
+		hosts[i] = host
+	}
+	return hosts
+}
+
+// foundLimit returns how many reachable hosts end the scan, or 0 for no limit
+func (s *Scanner) foundLimit() int {
+	if s.FirstOnly {
+		return 1
+	}
+	return s.StopAfterFound
+}
+
 // ScanSubnet scans a list of IPs and returns reachable ones with MAC addresses
 func (s *Scanner) ScanSubnet(ips []string, progressCallback ProgressCallback) *ScanResult {
+	return s.ScanSubnetContext(context.Background(), ips, progressCallback)
+}
+
+// ScanSubnetContext is like ScanSubnet but stops probing when ctx is cancelled.
+// Hosts found before cancellation are still returned.
+func (s *Scanner) ScanSubnetContext(ctx context.Context, ips []string, progressCallback ProgressCallback) *ScanResult {
+	// Stopping early cancels the probes, not the lookups of the BatchPTR stage
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The counters belong to the Scanner, so a scan reports what was sent
+	// while it ran rather than resetting them under a concurrent scan
+	probesBefore := s.probes.snapshot()
+
+	// The shared socket is this scan's own, so concurrent scans on one
+	// Scanner each get theirs
+	scanPinger := s.pinger
+	if !s.ICMPPerHostSocket {
+		shared := newSharedPinger(s.pinger)
+		defer shared.close()
+		scanPinger = shared
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var reachableHosts []HostInfo
+	var downHosts []HostInfo
 	var completed int
+	var found atomic.Int64
+	var reached int // Reachable hosts found, including those left out of the sample
+	// Hosts whose name is left to the BatchPTR stage
+	pendingNames := make(map[string]bool)
+
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = autoConcurrency(runtime.NumCPU(), len(ips))
+	}
+	s.logger.Debug("scan starting", "targets", len(ips), "concurrency", concurrency)
 
-	semaphore := make(chan struct{}, s.Concurrency)
+	semaphore := make(chan struct{}, concurrency)
 	total := len(ips)
 
+	limit := s.foundLimit()
+
+	var self map[string]bool
+	if !s.IncludeSelf {
+		self = localIPs()
+	}
+
 	for _, ip := range ips {
 		wg.Add(1)
 		go func(ip string) {
 			defer wg.Done()
-			semaphore <- struct{}{}
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-semaphore }()
 
-			start := time.Now() // Start timing for total process
-
-			// First, try ICMP ping and measure its response time
-			icmpReachable := false
-			var icmpResponseTime time.Duration
-			if reachable, responseTime := s.pingIP(ip); reachable {
-				icmpReachable = true
-				icmpResponseTime = responseTime
-			}
-			var openPorts []int
-			// Separate TCP and UDP scanning so UDP probes are only run when the host is known
-			// to be responsive (ICMP reply) or TCP scan found something. This avoids marking
-			// many UDP ports as open|filtered for hosts that are likely down/unreachable.
-			var tcpPorts []int
-			var udpPorts []int
-
-			if s.UseTCP {
-				tcpPorts = s.getOpenPorts(ip)
+			// The scan may have been cancelled while waiting for a slot
+			if ctx.Err() != nil {
+				return
 			}
 
-			if s.UseUDP {
-				if icmpReachable || len(tcpPorts) > 0 {
-					// Only perform UDP probes when host shows some responsiveness
-					udpPorts = s.getOpenUDPPorts(ip)
-				}
-			}
+			start := time.Now() // Start timing for total process
 
-			openPorts = append(openPorts, tcpPorts...)
-			openPorts = append(openPorts, udpPorts...)
+			// The scanning host's own addresses are listed without probing them,
+			// which would only measure the loopback path
+			var probe *hostProbe
+			if self[ip] {
+				probe = &hostProbe{ip: ip, responsive: limit == 0, self: true}
+			} else {
+				probe = s.probeHost(ctx, ip, scanPinger)
+			}
 
-			// Host is considered reachable if found via ICMP or has open TCP ports
-			isReachable := icmpReachable || len(openPorts) > 0
+			// Host is considered reachable if it answered a liveness probe or has open ports
+			isReachable := probe.responsive || len(probe.tcpPorts) > 0 || len(openUDPPorts(probe.udpResults)) > 0
 
 			if isReachable {
+				// Numbered before the MAC and DNS lookups, whose time says nothing about the host
+				seq := int(found.Add(1))
 				var mac, hostname string
 
-				// Only get MAC and hostname for ICMP-reachable hosts
-				if icmpReachable {
-					mac = s.macResolver.GetMACAddress(ip)
+				// Only get MAC and hostname for hosts that answered a liveness probe
+				if probe.responsive {
+					mac = s.macResolver.GetMACAddressContext(ctx, ip)
 
-					// Perform reverse DNS lookup
-					names, err := net.LookupAddr(ip)
-					if err == nil && len(names) > 0 {
-						// Return the first name, removing the trailing dot.
-						hostname = strings.TrimSuffix(names[0], ".")
+					if !s.BatchPTR {
+						hostname = s.lookupHostname(ctx, ip)
 					}
 				}
 				// For TCP-only hosts, leave MAC and hostname empty
@@ -143,14 +492,59 @@ func (s *Scanner) ScanSubnet(ips []string, progressCallback ProgressCallback) *S
 				processTime := time.Since(start) // Calculate duration
 
 				mu.Lock()
-				reachableHosts = append(reachableHosts, HostInfo{
+				// Concurrent probes may already have found enough hosts
+				if limit > 0 && reached >= limit {
+					mu.Unlock()
+					return
+				}
+				host := HostInfo{
 					IP:               ip,
+					Up:               true,
 					MAC:              mac,
 					Hostname:         hostname,
+					RequestedName:    s.targetNames[ip],
 					ProcessTime:      processTime,
-					ICMPResponseTime: icmpResponseTime,
-					OpenPorts:        openPorts,
-				})
+					ICMPResponseTime: probe.ping.RTT,
+					RTTJitter:        probe.ping.Jitter,
+					ICMPTries:        probe.ping.Sent,
+					OpenPorts:        portNumbers(probe.tcpPorts),
+					TCPPortResults:   probe.tcpPorts,
+					OpenUDPPorts:     openUDPPorts(probe.udpResults),
+					UDPResults:       probe.udpResults,
+					ResponderIP:      probe.ping.Responder,
+					Seq:              seq,
+				}
+				if probe.self {
+					host.Notes = append(host.Notes, "this host, not probed")
+				}
+				if host.ResponderIP != "" {
+					host.Notes = append(host.Notes, "ping reply from "+host.ResponderIP)
+				}
+				if probe.ping.Fallback != "" {
+					host.Notes = append(host.Notes, "answered ICMP "+probe.ping.Fallback+" only")
+				}
+				if probe.portsCapped {
+					host.Notes = append(host.Notes, fmt.Sprintf("stopped after %d open ports", s.MaxPortsPerHost))
+				}
+				if s.TCPRefusedUp && probe.tcpRefused && !probe.ping.Reachable && len(probe.tcpPorts) == 0 {
+					host.Notes = append(host.Notes, "TCP connection refused")
+				}
+				if s.BatchPTR && probe.responsive {
+					pendingNames[ip] = true
+				}
+				reached++
+				reachableHosts = s.keepSample(reachableHosts, host, reached)
+				s.logger.Info("host found", "ip", ip, "mac", mac, "hostname", hostname)
+				if s.HostFound != nil {
+					s.HostFound(host)
+				}
+				if limit > 0 && reached >= limit {
+					cancel()
+				}
+				mu.Unlock()
+			} else if s.RecordDown && ctx.Err() == nil {
+				mu.Lock()
+				downHosts = append(downHosts, HostInfo{IP: ip, RequestedName: s.targetNames[ip], ProcessTime: time.Since(start)})
 				mu.Unlock()
 			}
 
@@ -158,7 +552,7 @@ func (s *Scanner) ScanSubnet(ips []string, progressCallback ProgressCallback) *S
 			mu.Lock()
 			completed++
 			if progressCallback != nil {
-				progressCallback(completed, total, len(reachableHosts))
+				progressCallback(completed, total, reached)
 			}
 			mu.Unlock()
 		}(ip)
@@ -166,146 +560,565 @@ func (s *Scanner) ScanSubnet(ips []string, progressCallback ProgressCallback) *S
 
 	wg.Wait()
 
+	reachableHosts = mergeHosts(append(reachableHosts, downHosts...))
+	// This host's gateway means nothing on a network reached through a proxy
+	if limit == 0 && !s.proxied() {
+		reachableHosts = s.markGateway(ips, reachableHosts)
+	}
+	if s.BatchPTR && parent.Err() == nil {
+		s.resolveHostnames(parent, reachableHosts, pendingNames, progressCallback)
+	}
+
+	dropped := 0
+	if s.MaxResults > 0 && reached > s.MaxResults {
+		dropped = reached - s.MaxResults
+	}
+
 	// Sort results for consistent output
 	sort.Slice(reachableHosts, func(i, j int) bool {
-		ip1 := net.ParseIP(reachableHosts[i].IP)
-		ip2 := net.ParseIP(reachableHosts[j].IP)
-		if ip1 != nil && ip2 != nil {
-			ip1v4 := ip1.To4()
-			ip2v4 := ip2.To4()
-			if ip1v4 != nil && ip2v4 != nil {
-				return binary.BigEndian.Uint32(ip1v4) < binary.BigEndian.Uint32(ip2v4)
-			}
-		}
-		return reachableHosts[i].IP < reachableHosts[j].IP
+		return lessIP(reachableHosts[i].IP, reachableHosts[j].IP)
 	})
 
 	return &ScanResult{
 		ReachableHosts: reachableHosts,
+		Dropped:        dropped,
 		Total:          total,
 		Completed:      completed,
+		Probes:         s.probes.snapshot().since(probesBefore),
+	}
+}
+
+// localIPs returns the addresses of the scanning host's interfaces. Link-local
+// IPv6 addresses are included with their zone, as targets carry them.
+func localIPs() map[string]bool {
+	addrs, err := macaddr.LocalAddresses()
+	if err != nil {
+		return nil
+	}
+
+	local := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		ip := addr.Net.IP.String()
+		local[ip] = true
+		if addr.Net.IP.IsLinkLocalUnicast() && addr.Net.IP.To4() == nil {
+			local[withZone(ip, addr.Interface)] = true
+		}
 	}
+	return local
 }
 
-// getOpenPorts scans for open TCP ports on the target IP
-func (s *Scanner) getOpenPorts(ip string) []int {
-	commonPorts := []int{80, 443, 22, 21, 23, 25, 53, 135, 139, 445}
-	var openPorts []int
+// markGateway flags the default gateway among hosts. When it is a target but
+// ignored every probe, it is added anyway as long as its MAC is known.
+func (s *Scanner) markGateway(ips []string, hosts []HostInfo) []HostInfo {
+	gw, err := macaddr.DefaultGateway()
+	if err != nil {
+		s.logger.Debug("default gateway unknown", "error", err)
+		return hosts
+	}
+	ip := gw.String()
+
+	// With RecordDown a silent gateway is already listed, as down
+	down := -1
+	for i := range hosts {
+		if hosts[i].IP != ip {
+			continue
+		}
+		if hosts[i].Up {
+			hosts[i].Notes = append(hosts[i].Notes, "default gateway")
+			return hosts
+		}
+		down = i
+	}
+
+	if !slices.Contains(ips, ip) {
+		return hosts
+	}
+	mac := s.macResolver.GetMACAddress(ip)
+	if mac == "" {
+		return hosts
+	}
 
-	for _, port := range commonPorts {
+	s.logger.Info("gateway found in ARP table", "ip", ip, "mac", mac)
+	gateway := HostInfo{IP: ip, Up: true, MAC: mac, Notes: []string{"default gateway", "no reply, MAC from ARP table"}}
+	if down >= 0 {
+		hosts[down] = gateway
+		return hosts
+	}
+	return append(hosts, gateway)
+}
+
+// lookupResolver returns the resolver for DNS lookups
+func (s *Scanner) lookupResolver() resolver {
+	if s.resolver != nil {
+		return s.resolver
+	}
+	return net.DefaultResolver
+}
+
+// lookupHostname performs a reverse DNS lookup, giving up after ResolveTimeout so
+// a broken DNS server cannot stall the scan
+func (s *Scanner) lookupHostname(ctx context.Context, ip string) string {
+	ctx, cancel := context.WithTimeout(ctx, s.ResolveTimeout)
+	defer cancel()
+
+	// Reverse lookups do not take a zone
+	addr, _, _ := strings.Cut(ip, "%")
+	if !s.dnsLeaks.check(addr) {
+		return ""
+	}
+	names, err := s.lookupResolver().LookupAddr(ctx, addr)
+	if err != nil || len(names) == 0 {
+		if errors.Is(err, context.DeadlineExceeded) {
+			s.logger.Debug("reverse lookup timed out", "ip", ip)
+		}
+		return ""
+	}
+	// Return the first name, removing the trailing dot.
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// getOpenPorts scans for open TCP ports on the target IP, timing each handshake.
+// It also reports whether any closed port was refused with a reset.
+func (s *Scanner) getOpenPorts(ctx context.Context, ip string, maxOpen int) ([]TCPPortResult, bool) {
+	var openPorts []TCPPortResult
+	refused := false
+	d := s.tcpDialer(s.TCPTimeout)
+
+	for _, port := range s.TCPPorts {
+		if ctx.Err() != nil || (maxOpen > 0 && len(openPorts) >= maxOpen) {
+			break
+		}
 		address := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
-		conn, err := net.DialTimeout("tcp", address, s.Timeout)
+		dial := d
+		if timeout, ok := s.PortTimeouts[port]; ok {
+			dial = s.tcpDialer(timeout)
+		}
+		start := time.Now()
+		conn, err := dial.DialContext(ctx, "tcp", address)
+		rtt := time.Since(start)
+		s.probes.tcp.Add(1)
+		s.probes.bytes.Add(tcpSYNSize)
 		if err == nil {
+			s.probes.bytes.Add(2 * tcpHandshakeSize)
 			conn.Close()
-			openPorts = append(openPorts, port)
+			openPorts = append(openPorts, TCPPortResult{Port: port, RTT: rtt})
+			s.logger.Debug("tcp port open", "ip", ip, "port", port, "rtt", rtt)
+		} else if isConnRefused(err) {
+			refused = true
 		}
 	}
 
-	return openPorts
+	return openPorts, refused
 }
 
-func (s *Scanner) getOpenUDPPorts(ip string) []int {
-	udpPorts := []int{53, 67, 68, 69, 123, 137, 138, 161, 500, 514}
-	var open []int
-	dstIP := net.ParseIP(ip)
+// tcpDialer returns the dialer for TCP connections that give up after timeout
+func (s *Scanner) tcpDialer(timeout time.Duration) dialer {
+	switch d := s.dialer.(type) {
+	case nil:
+		return s.probeDialer(timeout)
+	case timeoutDialer:
+		return timeoutDialer{d.d, timeout}
+	}
+	return s.dialer
+}
+
+// portNumbers returns the port numbers of TCP port results
+func portNumbers(results []TCPPortResult) []int {
+	var ports []int
+	for _, result := range results {
+		ports = append(ports, result.Port)
+	}
+	return ports
+}
 
-	for _, port := range udpPorts {
-		raddr := &net.UDPAddr{IP: dstIP, Port: port}
+// defaultUDPHostConcurrency is how many UDP ports of one host are probed at
+// once when Scanner.UDPConcurrency is 0
+const defaultUDPHostConcurrency = 8
 
-		conn, err := net.DialUDP("udp", nil, raddr)
-		if err != nil {
-			// Can't dial UDP to this port — skip it
+// getUDPPorts probes the UDP ports of ip in parallel, at most
+// s.UDPConcurrency at a time, and returns the state of each in s.UDPPorts
+// order. With maxOpen set, no new probes start once that many ports are open.
+func (s *Scanner) getUDPPorts(ctx context.Context, ip string, maxOpen int) []UDPPortResult {
+	dst, ok := parseTargetIP(ip)
+	if !ok {
+		return nil
+	}
+	if s.UDPSingleSocket {
+		return s.getUDPPortsShared(ctx, ip, dst, maxOpen)
+	}
+
+	limit := s.UDPConcurrency
+	if limit <= 0 {
+		limit = defaultUDPHostConcurrency
+	}
+	semaphore := make(chan struct{}, limit)
+	results := make([]UDPPortResult, len(s.UDPPorts))
+	var open atomic.Int64
+
+	var wg sync.WaitGroup
+	for i, port := range s.UDPPorts {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil || (maxOpen > 0 && open.Load() >= int64(maxOpen)) {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[i] = s.probeUDPPort(ctx, ip, &net.UDPAddr{IP: dst.IP, Port: port, Zone: dst.Zone})
+			if results[i].State == UDPOpen {
+				open.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Ports never probed have no state. Probes already in flight at the limit
+	// may have found a few more open ports, which are dropped.
+	var probed []UDPPortResult
+	opened := 0
+	for _, result := range results {
+		if result.State == "" {
 			continue
 		}
+		if result.State == UDPOpen {
+			if maxOpen > 0 && opened >= maxOpen {
+				continue
+			}
+			opened++
+		}
+		probed = append(probed, result)
+	}
+	return probed
+}
+
+// probeUDPPort sends a probe to one UDP port and classifies it by the answer.
+// A port that could not be probed at all is returned without a state.
+func (s *Scanner) probeUDPPort(ctx context.Context, ip string, raddr *net.UDPAddr) UDPPortResult {
+	port := raddr.Port
+	result := UDPPortResult{Port: port}
+	conn, err := s.dialUDP(ctx, raddr)
+	if err != nil {
+		// Can't dial UDP to this port — skip it
+		s.logger.Warn("udp dial failed", "ip", ip, "port", port, "error", err)
+		return result
+	}
 
-		// Send a small probe. If the service replies on the same UDP socket we
-		// consider the port open. Otherwise we treat it as closed/filtered and
-		// do not report it.
-		_ = conn.SetDeadline(time.Now().Add(s.Timeout))
-		_, err = conn.Write([]byte("probe"))
+	// Send a small probe. If the service replies on the same UDP socket the
+	// port is open; an ICMP port unreachable comes back as a refused read and
+	// means it is closed. Silence leaves it open|filtered.
+	start := time.Now()
+	_ = conn.SetDeadline(time.Now().Add(s.UDPTimeout))
+	_, err = conn.Write(udpProbePayload)
+	s.countUDPProbe()
+	if err != nil {
+		// Retry once on write error
+		start = time.Now()
+		_ = conn.SetDeadline(time.Now().Add(s.UDPTimeout))
+		_, err = conn.Write(udpProbePayload)
+		s.countUDPProbe()
 		if err != nil {
-			// Retry once on write error
-			_ = conn.SetDeadline(time.Now().Add(s.Timeout))
-			_, _ = conn.Write([]byte("probe"))
+			conn.Close()
+			return result
 		}
+	}
 
-		// Attempt to read a reply from the service.
-		buf := make([]byte, 1500)
-		_ = conn.SetReadDeadline(time.Now().Add(s.Timeout))
-		n, _, err := conn.ReadFrom(buf)
-		conn.Close()
+	// Attempt to read a reply from the service.
+	buf := make([]byte, 1500)
+	_ = conn.SetReadDeadline(time.Now().Add(s.UDPTimeout))
+	n, _, err := conn.ReadFrom(buf)
+	conn.Close()
 
-		if err == nil && n > 0 {
-			// Received application-layer response — consider port open.
-			open = append(open, port)
-		}
-		// If no reply or read error, do not mark the port as open (avoid false positives).
+	switch {
+	case err == nil && n > 0:
+		// Received application-layer response — consider port open.
+		result.State, result.RTT = UDPOpen, time.Since(start)
+		s.logger.Debug("udp port open", "ip", ip, "port", port, "rtt", result.RTT)
+	case isConnRefused(err):
+		result.State = UDPClosed
+	default:
+		// No reply: only a reply proves the port open (avoid false positives)
+		result.State = UDPOpenFiltered
 	}
+	return result
+}
 
-	return open
+// udpProbePayload is the datagram sent to each probed UDP port
+var udpProbePayload = []byte("probe")
+
+// countUDPProbe records one UDP probe datagram
+func (s *Scanner) countUDPProbe() {
+	s.probes.udp.Add(1)
+	s.probes.bytes.Add(uint64(ipv4HeaderSize + udpHeaderSize + len(udpProbePayload)))
 }
 
-// pingIP sends an ICMP ping to an IP address and returns (success, duration)
-func (s *Scanner) pingIP(ip string) (bool, time.Duration) {
-	dst, err := net.ResolveIPAddr("ip4", ip)
+// CheckICMP reports whether the raw socket needed for ICMP ping can be opened.
+// Without it only TCP and UDP probes can find hosts.
+func (s *Scanner) CheckICMP() error {
+	conn, err := s.pinger.listen(icmpV4)
 	if err != nil {
-		return false, 0
+		return err
+	}
+	return conn.Close()
+}
+
+// pingIP sends s.Count ICMP echo requests to an IP address over a socket from
+// p and collects the replies
+func (s *Scanner) pingIP(ctx context.Context, p pinger, ip string) pingResult {
+	var result pingResult
+
+	dst, ok := parseTargetIP(ip)
+	if !ok {
+		s.logger.Warn("icmp target invalid", "ip", ip)
+		return result
+	}
+	family := icmpV4
+	if dst.IP.To4() == nil {
+		family = icmpV6
 	}
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	conn, err := p.listen(family)
 	if err != nil {
-		return false, 0
+		s.logger.Warn("icmp listen failed", "ip", ip, "error", err)
+		result.Err = err
+		return result
 	}
 	defer conn.Close()
 
-	message := &icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  1,
-			Data: []byte("ping"),
-		},
+	count := max(s.Count, 1)
+	id := os.Getpid() & 0xffff
+	var rtts []time.Duration
+
+	for i := 0; i < count && ctx.Err() == nil; i++ {
+		reply, tries, ok, err := s.echo(ctx, conn, family, dst, id)
+		result.Sent += tries
+		if err != nil {
+			result.Err = err
+			break
+		}
+		if ok {
+			rtts = append(rtts, reply.RTT)
+			if reply.Responder != nil {
+				result.Responder = reply.Responder.String()
+			}
+		}
 	}
 
-	data, err := message.Marshal(nil)
-	if err != nil {
-		return false, 0
+	result.Received = len(rtts)
+	if result.Received == 0 {
+		// Timestamp and address mask requests only exist in ICMPv4
+		if s.ICMPFallback && family == icmpV4 && ctx.Err() == nil {
+			if rtt, name, ok := s.queryFallback(ctx, conn, dst, id); ok {
+				result.Reachable = true
+				result.RTT = rtt
+				result.Fallback = name
+			}
+		}
+		return result
 	}
 
-	deadline := time.Now().Add(s.Timeout)
-	conn.SetDeadline(deadline)
+	result.Reachable = true
+	result.RTT = averageDuration(rtts)
+	result.Jitter = rttJitter(rtts)
+	return result
+}
+
+// echo sends an ICMP echo request and waits for the matching reply. With
+// ICMPRetries set, the timeout is split into exponentially growing windows and
+// the request is resent each time a window passes without a reply, so the
+// total wait never exceeds s.PingTimeout. It returns the reply and the number
+// of requests sent, or an error when a request could not be sent.
+func (s *Scanner) echo(ctx context.Context, conn net.PacketConn, family icmpFamily, dst *net.IPAddr, id int) (echoReply, int, bool, error) {
+	// Library callers skip the flag validation, and a value out of range would
+	// divide by zero or shift the windows to nothing
+	tries := min(max(s.ICMPRetries, 0), maxICMPRetries) + 1
+	window := s.PingTimeout / time.Duration(1<<tries-1)
 
-	start := time.Now()
-	_, err = conn.WriteTo(data, dst)
-	if err != nil {
-		return false, 0
+	// A late reply to an earlier try still counts, so remember every request sent
+	sent := make(map[int]time.Time, tries)
+	payload := s.echoPayload()
+
+	for try := 0; try < tries && ctx.Err() == nil; try++ {
+		if try > 0 {
+			s.logger.Debug("icmp retry", "ip", dst.String(), "try", try+1)
+		}
+
+		// Every request gets its own sequence number so replies can be matched exactly
+		seq := int(s.echoSeq.Add(1) & 0xffff)
+		message := &icmp.Message{
+			Type: family.echoRequest,
+			Code: 0,
+			Body: &icmp.Echo{
+				ID:   id,
+				Seq:  seq,
+				Data: payload,
+			},
+		}
+
+		data, err := message.Marshal(nil)
+		if err != nil {
+			return echoReply{}, try, false, err
+		}
+
+		deadline := time.Now().Add(window << try)
+		conn.SetDeadline(deadline)
+
+		sent[seq] = time.Now()
+		_, err = conn.WriteTo(data, dst)
+		if err != nil {
+			s.logger.Warn("icmp send failed", "ip", dst.String(), "error", err)
+			return echoReply{}, try, false, err
+		}
+		s.probes.icmp.Add(1)
+		s.probes.bytes.Add(uint64(family.headerSize + len(data)))
+
+		if rtt, responder, ok := s.awaitEchoReply(ctx, conn, family, dst, id, sent, deadline); ok {
+			s.logger.Debug("icmp echo reply", "ip", dst.String(), "rtt", rtt, "tries", try+1)
+			return echoReply{RTT: rtt, Responder: responder}, try + 1, true, nil
+		}
+	}
+
+	return echoReply{}, tries, false, nil
+}
+
+// echoPayload returns the echo request data, "ping" repeated to ProbeSize bytes
+func (s *Scanner) echoPayload() []byte {
+	if s.ProbeSize <= 0 {
+		return []byte("ping")
 	}
 
+	payload := make([]byte, s.ProbeSize)
+	for i := range payload {
+		payload[i] = "ping"[i%4]
+	}
+	return payload
+}
+
+// echoReply describes the reply received for an echo request
+type echoReply struct {
+	RTT       time.Duration
+	Responder net.IP // Set when the reply came from an address other than the target
+}
+
+// awaitEchoReply reads from conn until deadline, returning the RTT of the first
+// echo reply from dst that matches one of the sent sequence numbers. With
+// DetectForeignReplies set, a matching reply from another address is accepted
+// too and its source is returned as the responder.
+func (s *Scanner) awaitEchoReply(ctx context.Context, conn net.PacketConn, family icmpFamily, dst *net.IPAddr, id int, sent map[int]time.Time, deadline time.Time) (time.Duration, net.IP, bool) {
 	reply := make([]byte, 1500)
-	for time.Now().Before(deadline) {
-		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-		_, peer, err := conn.ReadFrom(reply)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		conn.SetReadDeadline(minTime(time.Now().Add(100*time.Millisecond), deadline))
+		n, peer, err := conn.ReadFrom(reply)
 		if err != nil {
 			continue
 		}
 
-		if peerIP, ok := peer.(*net.IPAddr); ok {
-			if peerIP.IP.Equal(dst.IP) && len(reply) > 0 {
-				return true, time.Since(start)
-			}
+		peerIP, ok := peer.(*net.IPAddr)
+		if !ok {
+			continue
 		}
+		fromTarget := peerIP.IP.Equal(dst.IP)
+		if !fromTarget && !s.DetectForeignReplies {
+			continue
+		}
+
+		// Raw sockets see every ICMP packet, so only accept our own echo reply
+		msg, err := icmp.ParseMessage(family.protocol, reply[:n])
+		if err != nil || msg.Type != family.echoReply {
+			continue
+		}
+		body, ok := msg.Body.(*icmp.Echo)
+		if !ok || body.ID != id {
+			continue
+		}
+		start, ok := sent[body.Seq]
+		if !ok {
+			continue
+		}
+
+		if !fromTarget {
+			s.logger.Warn("icmp reply from unexpected source", "ip", dst.String(), "responder", peerIP.IP.String())
+			return time.Since(start), peerIP.IP, true
+		}
+		return time.Since(start), nil, true
+	}
+
+	return 0, nil, false
+}
+
+// minTime returns the earlier of two times
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
 	}
+	return b
+}
 
-	return false, 0
+// averageDuration returns the arithmetic mean of a non-empty list of durations
+func averageDuration(durations []time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
 }
 
-// incrementIP increments an IP address by one
-func incrementIP(ip net.IP) {
+// rttJitter returns the mean absolute difference between consecutive round-trip times
+func rttJitter(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
+		return 0
+	}
+
+	var total time.Duration
+	for i := 1; i < len(rtts); i++ {
+		diff := rtts[i] - rtts[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		total += diff
+	}
+	return total / time.Duration(len(rtts)-1)
+}
+
+// incrementIP increments an IP address by one. It returns false when the
+// increment overflowed every byte, i.e. the address wrapped around to zero.
+func incrementIP(ip net.IP) bool {
 	for j := len(ip) - 1; j >= 0; j-- {
 		ip[j]++
 		if ip[j] > 0 {
-			break
+			return true
 		}
 	}
+	return false
+}
+
+// lessIP orders addresses numerically, IPv4 before IPv6, falling back to
+// string order for anything that is not an IP address. IPv6 zones are
+// compared after the address.
+func lessIP(a, b string) bool {
+	host1, zone1, _ := strings.Cut(a, "%")
+	host2, zone2, _ := strings.Cut(b, "%")
+	ip1 := net.ParseIP(host1)
+	ip2 := net.ParseIP(host2)
+	if ip1 == nil || ip2 == nil {
+		return a < b
+	}
+
+	ip1v4 := ip1.To4()
+	ip2v4 := ip2.To4()
+	if (ip1v4 == nil) != (ip2v4 == nil) {
+		return ip1v4 != nil
+	}
+	if ip1v4 != nil {
+		return binary.BigEndian.Uint32(ip1v4) < binary.BigEndian.Uint32(ip2v4)
+	}
+
+	if c := bytes.Compare(ip1.To16(), ip2.To16()); c != 0 {
+		return c < 0
+	}
+	return zone1 < zone2
 }
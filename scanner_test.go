@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestAutoConcurrency(t *testing.T) {
+	tests := []struct {
+		name          string
+		cpus, targets int
+		want          int
+	}{
+		{"per CPU", 4, 1000, 4 * concurrencyPerCPU},
+		{"minimum clamp", 0, 1000, minAutoConcurrency},
+		{"single CPU", 1, 1000, max(concurrencyPerCPU, minAutoConcurrency)},
+		{"maximum clamp", 64, 100000, maxAutoConcurrency},
+		{"fewer targets than the clamp", 64, 10, 10},
+		{"fewer targets than per CPU", 4, 20, 20},
+		{"fewer targets than the minimum", 1, 3, 3},
+		{"no targets", 4, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoConcurrency(tt.cpus, tt.targets); got != tt.want {
+				t.Errorf("autoConcurrency(%d, %d) = %d, want %d", tt.cpus, tt.targets, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLessIP(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		// String order would put these the wrong way round
+		{"fe80::2", "fe80::10", true},
+		{"fe80::10", "fe80::2", false},
+		{"2001:db8::9", "2001:db8::a", true},
+		{"10.0.0.9", "10.0.0.10", true},
+		{"10.0.0.10", "10.0.0.9", false},
+		// IPv4 sorts before IPv6
+		{"192.168.1.1", "::1", true},
+		{"::1", "192.168.1.1", false},
+		{"255.255.255.255", "2001:db8::1", true},
+		// IPv4-mapped addresses sort with IPv4
+		{"::ffff:10.0.0.2", "10.0.0.10", true},
+		// Zones are compared after the address
+		{"fe80::2%eth0", "fe80::10%eth0", true},
+		{"fe80::10%eth0", "fe80::2%wlan0", false},
+		{"fe80::1%eth0", "fe80::1%eth1", true},
+		{"fe80::1%eth1", "fe80::1%eth0", false},
+		{"fe80::1", "fe80::1%eth0", true},
+		// Equal addresses are not less
+		{"10.0.0.1", "10.0.0.1", false},
+		{"fe80::1%eth0", "fe80::1%eth0", false},
+		// Anything else falls back to string order
+		{"host-a", "host-b", true},
+		{"10.0.0.1", "host-a", true},
+	}
+
+	for _, tt := range tests {
+		if got := lessIP(tt.a, tt.b); got != tt.want {
+			t.Errorf("lessIP(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
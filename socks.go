@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// socks5Dialer returns a dialer that opens the TCP connect scan's connections
+// through the SOCKS5 proxy at addr, given as host:port or
+// socks5://[user:password@]host:port. The proxy itself is reached with
+// forward.
+func socks5Dialer(addr string, forward *net.Dialer) (dialer, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "socks5://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != "socks5" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy %q: want host:port or socks5://[user:password@]host:port", addr)
+	}
+
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+
+	d, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+	if err != nil {
+		return nil, err
+	}
+	return timeoutDialer{d.(proxy.ContextDialer), forward.Timeout}, nil
+}
+
+// timeoutDialer bounds each connection, including the proxy handshake, by
+// timeout. The SOCKS5 dialer only applies the forward dialer's timeout to
+// reaching the proxy, so a target the proxy cannot reach would otherwise
+// hang for as long as the proxy waits.
+type timeoutDialer struct {
+	d       proxy.ContextDialer
+	timeout time.Duration
+}
+
+func (t timeoutDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.d.DialContext(ctx, network, address)
+}
+
+// proxied reports whether the TCP connect scan goes through a proxy, so the
+// targets are not on this host's networks
+func (s *Scanner) proxied() bool {
+	_, ok := s.dialer.(timeoutDialer)
+	return ok
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// seenState is the set of IP and MAC pairs found by the previous scan, kept
+// in a file so recurring scans can report only what is new since then. Unlike
+// a baseline it is replaced after every scan.
+type seenState map[string]bool
+
+// seenHost is one entry of a saved state file
+type seenHost struct {
+	IP  string `json:"ip"`
+	MAC string `json:"mac,omitempty"`
+}
+
+// stateKey identifies a host by IP and MAC, so a new device taking over a
+// known IP counts as new. Hosts off the local link have no MAC.
+func stateKey(ip, mac string) string {
+	return ip + " " + mac
+}
+
+// loadState reads a state file. A missing file is an empty state, which makes
+// every host of the first scan new.
+func loadState(path string) (seenState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return seenState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	var hosts []seenHost
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+
+	state := make(seenState, len(hosts))
+	for _, host := range hosts {
+		state[stateKey(host.IP, host.MAC)] = true
+	}
+	return state, nil
+}
+
+// saveState replaces the state file with the hosts up in the latest scan
+func saveState(path string, hosts []HostInfo) (int, error) {
+	var seen []seenHost
+	for _, host := range hosts {
+		if host.Up && !host.Offline {
+			seen = append(seen, seenHost{IP: host.IP, MAC: host.MAC})
+		}
+	}
+	sort.Slice(seen, func(i, j int) bool { return lessIP(seen[i].IP, seen[j].IP) })
+
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return 0, fmt.Errorf("failed to save state: %w", err)
+	}
+	return len(seen), nil
+}
+
+// newHostFilter keeps the hosts up now that were not in the state
+func newHostFilter(state seenState) hostFilter {
+	return func(host HostInfo) bool {
+		return host.Up && !host.Offline && !state[stateKey(host.IP, host.MAC)]
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// dialer opens the connections of the TCP connect scan. *net.Dialer implements
+// it; tests substitute fakes to simulate hosts without touching the network.
+type dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// probeDialer returns the dialer for TCP and UDP probes, bound to SourceIP
+// and SourcePort when they are set. Concurrent probes share the port through
+// SO_REUSEADDR.
+func (s *Scanner) probeDialer(timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if s.SourceIP != nil || s.SourcePort > 0 {
+		// The dialer picks the matching address family for the nil IP
+		d.LocalAddr = &net.TCPAddr{IP: s.SourceIP, Port: s.SourcePort}
+	}
+	if s.SourcePort > 0 {
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			return setReuseAddr(c)
+		}
+	}
+	return d
+}
+
+// udpProbeRecvBuffer is the receive buffer of a per-port UDP probe socket,
+// which only ever reads one short reply
+const udpProbeRecvBuffer = 4096
+
+// dialUDP opens a UDP socket connected to raddr, from SourceIP and
+// SourcePort when set, tuned with tuneUDPProbeSocket for dense scans
+func (s *Scanner) dialUDP(ctx context.Context, raddr *net.UDPAddr) (*net.UDPConn, error) {
+	d := s.probeDialer(s.UDPTimeout)
+	if d.LocalAddr != nil {
+		d.LocalAddr = &net.UDPAddr{IP: s.SourceIP, Port: s.SourcePort}
+	}
+	d.Control = func(_, _ string, c syscall.RawConn) error {
+		if s.SourcePort > 0 {
+			if err := setReuseAddr(c); err != nil {
+				return err
+			}
+		}
+		return tuneUDPProbeSocket(c)
+	}
+
+	conn, err := d.DialContext(ctx, "udp", raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// resolver performs the forward and reverse DNS lookups of a scan.
+// *net.Resolver implements it; -doh swaps in a DNS-over-HTTPS resolver.
+type resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// pinger opens the socket ICMP echo requests are sent and received on. Like
+// dialer, it lets tests and benchmarks fake up and down hosts.
+type pinger interface {
+	listen(family icmpFamily) (net.PacketConn, error)
+}
+
+// icmpFamily holds what differs between ICMP echo over IPv4 and IPv6
+type icmpFamily struct {
+	network     string // Raw socket network for net.ListenPacket
+	address     string // Wildcard address to listen on
+	protocol    int    // IANA protocol number, for icmp.ParseMessage
+	echoRequest icmp.Type
+	echoReply   icmp.Type
+	headerSize  int // IP header size, for probe accounting
+}
+
+var (
+	icmpV4 = icmpFamily{"ip4:icmp", "0.0.0.0", 1, ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply, ipv4HeaderSize}
+	icmpV6 = icmpFamily{"ip6:ipv6-icmp", "::", 58, ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, ipv6HeaderSize}
+)
+
+// rawPinger sends echo requests on a raw ICMP socket
+type rawPinger struct{ s *Scanner }
+
+func (p rawPinger) listen(family icmpFamily) (net.PacketConn, error) {
+	return listenICMP(family, p.s.DontFragment, p.s.SourceIP)
+}
+
+// listenICMP opens the raw socket used for ICMP echo, optionally with the
+// don't-fragment bit set. IPv6 routers never fragment, so it only applies to
+// IPv4. A source address of the family binds the socket to it. Permission
+// failures are reported as ErrNoRawSocket.
+func listenICMP(family icmpFamily, dontFragment bool, source net.IP) (net.PacketConn, error) {
+	address := family.address
+	if source != nil && (source.To4() != nil) == (family == icmpV4) {
+		address = source.String()
+	}
+
+	var conn net.PacketConn
+	var err error
+	if dontFragment && family == icmpV4 {
+		lc := net.ListenConfig{Control: func(_, _ string, c syscall.RawConn) error {
+			return setDontFragment(c)
+		}}
+		conn, err = lc.ListenPacket(context.Background(), family.network, address)
+	} else {
+		var ic *icmp.PacketConn
+		if ic, err = icmp.ListenPacket(family.network, address); err == nil {
+			conn = ic
+		}
+	}
+
+	if errors.Is(err, os.ErrPermission) {
+		return nil, fmt.Errorf("%w: %w", ErrNoRawSocket, err)
+	}
+	return conn, err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// fakeNetwork simulates the hosts of a scan: the IPs that answer ping and
+// the TCP addresses (ip:port) that accept connections. It implements dialer,
+// pinger and resolver so a Scanner can run without touching the network.
+type fakeNetwork struct {
+	up   map[string]bool
+	open map[string]bool
+	rtt  time.Duration
+}
+
+func (n *fakeNetwork) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !n.open[address] {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func (n *fakeNetwork) listen(family icmpFamily) (net.PacketConn, error) {
+	return &fakeICMPConn{net: n, family: family, replies: make(chan icmpPacket, 1024), closed: make(chan struct{})}, nil
+}
+
+func (n *fakeNetwork) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return []string{"host-" + addr + ".test."}, nil
+}
+
+func (n *fakeNetwork) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return nil, fmt.Errorf("no such host %s", host)
+}
+
+// fakeICMPConn answers the echo requests written to it for the network's up
+// hosts after the network's round-trip time
+type fakeICMPConn struct {
+	net     *fakeNetwork
+	family  icmpFamily
+	replies chan icmpPacket
+	closed  chan struct{}
+
+	mu       sync.Mutex
+	deadline time.Time
+	once     sync.Once
+}
+
+func (c *fakeICMPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	dst := addr.(*net.IPAddr)
+	msg, err := icmp.ParseMessage(c.family.protocol, b)
+	if err != nil {
+		return 0, err
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || msg.Type != c.family.echoRequest || !c.net.up[dst.IP.String()] {
+		return len(b), nil
+	}
+
+	reply, err := (&icmp.Message{Type: c.family.echoReply, Body: echo}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	time.AfterFunc(c.net.rtt, func() {
+		select {
+		case c.replies <- icmpPacket{data: reply, peer: &net.IPAddr{IP: dst.IP}}:
+		case <-c.closed:
+		}
+	})
+	return len(b), nil
+}
+
+func (c *fakeICMPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case packet := <-c.replies:
+		return copy(b, packet.data), packet.peer, nil
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *fakeICMPConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeICMPConn) LocalAddr() net.Addr { return &net.IPAddr{} }
+
+func (c *fakeICMPConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *fakeICMPConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *fakeICMPConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// fakeScanner returns a scanner whose probes and lookups all go to n
+func fakeScanner(n *fakeNetwork) *Scanner {
+	s := NewScanner()
+	s.pinger = n
+	s.dialer = n
+	s.resolver = n
+	s.PingTimeout = 50 * time.Millisecond
+	s.TCPTimeout = 50 * time.Millisecond
+	return s
+}
+
+// fakeTargets returns count addresses of the 198.51.100.0/24 documentation
+// network, which no test host is on
+func fakeTargets(count int) []string {
+	ips := make([]string, count)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("198.51.100.%d", i+1)
+	}
+	return ips
+}
+
+func TestScanSubnetContext(t *testing.T) {
+	n := &fakeNetwork{
+		up:   map[string]bool{"198.51.100.1": true, "198.51.100.5": true},
+		open: map[string]bool{"198.51.100.5:22": true, "198.51.100.9:80": true},
+		rtt:  time.Millisecond,
+	}
+	s := fakeScanner(n)
+	s.UseTCP = true
+	s.TCPPorts = []int{22, 80}
+
+	var progress int
+	result := s.ScanSubnetContext(context.Background(), fakeTargets(16), func(completed, total, found int) {
+		progress = completed
+	})
+
+	if result.Total != 16 || result.Completed != 16 || progress != 16 {
+		t.Errorf("total %d, completed %d, progress %d; want 16 each", result.Total, result.Completed, progress)
+	}
+
+	want := []struct {
+		ip       string
+		hostname string
+		ports    []int
+		pinged   bool
+	}{
+		{"198.51.100.1", "host-198.51.100.1.test", nil, true},
+		{"198.51.100.5", "host-198.51.100.5.test", []int{22}, true},
+		// Found by TCP alone, so neither the MAC nor the name is looked up
+		{"198.51.100.9", "", []int{80}, false},
+	}
+	if len(result.ReachableHosts) != len(want) {
+		t.Fatalf("found %d hosts, want %d: %+v", len(result.ReachableHosts), len(want), result.ReachableHosts)
+	}
+	for i, w := range want {
+		host := result.ReachableHosts[i]
+		if host.IP != w.ip || host.Hostname != w.hostname || !slices.Equal(host.OpenPorts, w.ports) || (host.ICMPResponseTime > 0) != w.pinged {
+			t.Errorf("host %d = %s %q ports %v rtt %s; want %s %q ports %v pinged %v",
+				i, host.IP, host.Hostname, host.OpenPorts, host.ICMPResponseTime, w.ip, w.hostname, w.ports, w.pinged)
+		}
+	}
+
+	// Every target is pinged once and has both ports tried
+	if result.Probes.ICMP != 16 || result.Probes.TCP != 32 {
+		t.Errorf("sent %d ICMP and %d TCP probes, want 16 and 32", result.Probes.ICMP, result.Probes.TCP)
+	}
+}
+
+func TestScanSubnetContextConcurrent(t *testing.T) {
+	n := &fakeNetwork{up: map[string]bool{"198.51.100.1": true, "198.51.100.2": true}, rtt: time.Millisecond}
+	s := fakeScanner(n)
+
+	// Two scans on one Scanner each get their own shared ICMP socket
+	var wg sync.WaitGroup
+	results := make([]*ScanResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.ScanSubnetContext(context.Background(), fakeTargets(8), nil)
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if len(result.ReachableHosts) != 2 || result.Completed != 8 {
+			t.Errorf("scan %d found %d hosts in %d targets, want 2 in 8", i, len(result.ReachableHosts), result.Completed)
+		}
+	}
+	if _, ok := s.pinger.(*fakeNetwork); !ok {
+		t.Errorf("the scanner's pinger is now %T, want it left alone", s.pinger)
+	}
+}
+
+func BenchmarkScanSubnetContext(b *testing.B) {
+	ips := fakeTargets(254)
+	n := &fakeNetwork{up: make(map[string]bool), rtt: 100 * time.Microsecond}
+	for _, ip := range ips[:64] {
+		n.up[ip] = true
+	}
+	s := fakeScanner(n)
+	s.PingTimeout = 5 * time.Millisecond
+
+	for b.Loop() {
+		s.ScanSubnetContext(context.Background(), ips, nil)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Columns the interactive view can be sorted by, cycled with the "s" key
+var tuiSortKeys = []string{"ip", "rtt", "vendor", "hostname"}
+
+// tuiRefresh is how often the interactive view is redrawn while scanning
+const tuiRefresh = 200 * time.Millisecond
+
+// tui is a full-screen, live-updating view of a scan. Hosts are added from
+// the scanner's HostFound callback while keys are read from the terminal.
+type tui struct {
+	scanner *Scanner
+	ips     []string
+	title   string
+
+	mu        sync.Mutex
+	hosts     []HostInfo
+	completed int
+	total     int
+	scanning  bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	sortKey   int
+	filter    string
+	filtering bool
+	cursor    int
+	offset    int
+	detail    bool
+}
+
+// runTUI scans ips in an interactive full-screen view until the user quits
+func runTUI(scanner *Scanner, ips []string, title string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("-tui needs an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// Alternate screen, hidden cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	t := &tui{scanner: scanner, ips: ips, title: title}
+	scanner.HostFound = t.addHost
+	t.mu.Lock()
+	t.startScan()
+	t.mu.Unlock()
+
+	keys := make(chan []byte)
+	go readKeys(keys)
+
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+
+	t.draw()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !t.handleKey(key) {
+				t.stopScan()
+				return nil
+			}
+		case <-ticker.C:
+		}
+		t.draw()
+	}
+}
+
+// readKeys forwards raw key presses from stdin until it is closed
+func readKeys(keys chan<- []byte) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		key := make([]byte, n)
+		copy(key, buf[:n])
+		keys <- key
+	}
+}
+
+// startScan clears the current hosts and scans every address again.
+// It must be called with t.mu held.
+func (t *tui) startScan() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t.hosts = nil
+	t.completed, t.total = 0, len(t.ips)
+	t.scanning = true
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	done := t.done
+
+	go func() {
+		defer close(done)
+		t.scanner.ScanSubnetContext(ctx, t.ips, t.setProgress)
+
+		t.mu.Lock()
+		t.scanning = false
+		t.mu.Unlock()
+	}()
+}
+
+// stopScan cancels the running scan and waits for it to finish
+func (t *tui) stopScan() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (t *tui) addHost(host HostInfo) {
+	t.mu.Lock()
+	t.hosts = append(t.hosts, host)
+	t.mu.Unlock()
+}
+
+func (t *tui) setProgress(completed, total, found int) {
+	t.mu.Lock()
+	t.completed, t.total = completed, total
+	t.mu.Unlock()
+}
+
+// handleKey applies a key press and reports whether the view should stay open
+func (t *tui) handleKey(key []byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.filtering {
+		switch k := string(key); {
+		case k == "\r" || k == "\033":
+			t.filtering = false
+		case k == "\x7f" || k == "\b":
+			if t.filter != "" {
+				t.filter = t.filter[:len(t.filter)-1]
+			}
+		case len(k) == 1 && k[0] >= ' ':
+			t.filter += k
+		}
+		t.cursor, t.offset = 0, 0
+		return true
+	}
+
+	switch string(key) {
+	case "q", "\x03":
+		return false
+	case "\033[A", "k":
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case "\033[B", "j":
+		t.cursor++
+	case "s":
+		t.sortKey = (t.sortKey + 1) % len(tuiSortKeys)
+	case "/":
+		t.filtering = true
+	case "\r":
+		t.detail = !t.detail
+	case "\033":
+		t.detail = false
+	case "r":
+		if !t.scanning {
+			t.startScan()
+			t.detail = false
+		}
+	}
+	return true
+}
+
+// visibleHosts returns the hosts matching the filter, in the selected order
+func (t *tui) visibleHosts() []HostInfo {
+	filter := strings.ToLower(t.filter)
+
+	var hosts []HostInfo
+	for _, host := range t.hosts {
+		text := strings.ToLower(host.IP + " " + host.Hostname + " " + host.MAC + " " + mac2manufacturer(host.MAC))
+		if strings.Contains(text, filter) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	key := tuiSortKeys[t.sortKey]
+	sort.SliceStable(hosts, func(i, j int) bool {
+		switch key {
+		case "rtt":
+			return hosts[i].ResponseTime() < hosts[j].ResponseTime()
+		case "vendor":
+			return mac2manufacturer(hosts[i].MAC) < mac2manufacturer(hosts[j].MAC)
+		case "hostname":
+			return hosts[i].Hostname < hosts[j].Hostname
+		}
+		return lessIP(hosts[i].IP, hosts[j].IP)
+	})
+	return hosts
+}
+
+// draw repaints the whole screen
+func (t *tui) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	hosts := t.visibleHosts()
+	if t.cursor >= len(hosts) {
+		t.cursor = max(len(hosts)-1, 0)
+	}
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+
+	status := "done"
+	if t.scanning {
+		status = "scanning"
+	}
+	line(&b, width, fmt.Sprintf("neti %s - %s %d/%d, %d up   sort: %s", t.title, status, t.completed, t.total, len(t.hosts), tuiSortKeys[t.sortKey]))
+	if t.filtering || t.filter != "" {
+		line(&b, width, "filter: "+t.filter)
+	} else {
+		line(&b, width, "")
+	}
+
+	if t.detail && len(hosts) > 0 {
+		t.drawDetail(&b, width, hosts[t.cursor])
+	} else {
+		t.drawTable(&b, width, height-4, hosts)
+	}
+
+	fmt.Fprintf(&b, "\033[%d;1H", height)
+	b.WriteString("\033[7m")
+	line(&b, width, "q quit  ↑/↓ move  enter details  s sort  / filter  r re-scan")
+	b.WriteString("\033[0m")
+
+	fmt.Print(b.String())
+}
+
+// drawTable lists hosts, scrolled so the cursor stays in view
+func (t *tui) drawTable(b *strings.Builder, width, rows int, hosts []HostInfo) {
+	line(b, width, fmt.Sprintf("%-15s  %-17s  %-9s  %-28s  %s", "IP", "MAC", "RTT", "Manufacturer", "Hostname"))
+
+	rows--
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if rows > 0 && t.cursor >= t.offset+rows {
+		t.offset = t.cursor - rows + 1
+	}
+
+	for i := t.offset; i < len(hosts) && i < t.offset+rows; i++ {
+		host := hosts[i]
+		rtt := "N/A"
+		if host.ResponseTime() > 0 {
+			rtt = host.ResponseTime().Round(10 * time.Microsecond).String()
+		}
+		row := fmt.Sprintf("%-15s  %-17s  %-9s  %-28.28s  %s", host.IP, host.MAC, rtt, vendorLabel(host.MAC), host.Hostname)
+		if i == t.cursor {
+			b.WriteString("\033[7m")
+			line(b, width, row)
+			b.WriteString("\033[0m")
+			continue
+		}
+		line(b, width, row)
+	}
+}
+
+// drawDetail shows everything known about a single host
+func (t *tui) drawDetail(b *strings.Builder, width int, host HostInfo) {
+	fields := [][2]string{
+		{"IP", host.IP},
+		{"Hostname", host.Hostname},
+		{"Requested Name", host.RequestedName},
+		{"MAC", host.MAC},
+		{"Manufacturer", vendorLabel(host.MAC)},
+		{"Virtual", virtualLabel(host.MAC)},
+		{"LLDP", formatLLDP(host)},
+		{"ICMP Time", host.ICMPResponseTime.String()},
+		{"Jitter", host.RTTJitter.String()},
+		{"Open Ports", formatPorts(host.OpenPorts, host.OpenUDPPorts)},
+		{"Process Time", formatProcessTime(host.ProcessTime)},
+	}
+	if host.ResponderIP != "" {
+		fields = append(fields, [2]string{"Responder", host.ResponderIP})
+	}
+	for _, note := range host.Notes {
+		fields = append(fields, [2]string{"Note", note})
+	}
+
+	for _, field := range fields {
+		line(b, width, fmt.Sprintf("%-14s %s", field[0], field[1]))
+	}
+}
+
+// line writes s truncated to the terminal width. Raw mode needs explicit
+// carriage returns.
+func line(b *strings.Builder, width int, s string) {
+	if r := []rune(s); len(r) > width {
+		s = string(r[:width])
+	}
+	b.WriteString(s)
+	b.WriteString("\033[K\r\n")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// getUDPPortsShared probes every UDP port of dst from a single unconnected
+// socket and matches replies to ports by their source port. A dense scan
+// then needs one local port per host instead of one per probe, which keeps
+// it clear of ephemeral port exhaustion. An unconnected socket is not told
+// about ICMP port unreachable, so closed ports read as open|filtered.
+func (s *Scanner) getUDPPortsShared(ctx context.Context, ip string, dst *net.IPAddr, maxOpen int) []UDPPortResult {
+	conn, err := s.listenUDP(ctx, dst.IP.To4() != nil)
+	if err != nil {
+		s.logger.Warn("udp listen failed", "ip", ip, "error", err)
+		return nil
+	}
+	defer conn.Close()
+
+	// The probes all go out at once, so one timeout covers the slowest
+	// answer. Cancelling the scan cuts it short.
+	_ = conn.SetReadDeadline(time.Now().Add(s.UDPTimeout))
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	sent := make(map[int]time.Time, len(s.UDPPorts))
+	for _, port := range s.UDPPorts {
+		if ctx.Err() != nil {
+			break
+		}
+		raddr := &net.UDPAddr{IP: dst.IP, Port: port, Zone: dst.Zone}
+		start := time.Now()
+		_, err := conn.WriteToUDP(udpProbePayload, raddr)
+		s.countUDPProbe()
+		if err != nil {
+			// Retry once on write error
+			start = time.Now()
+			_, err = conn.WriteToUDP(udpProbePayload, raddr)
+			s.countUDPProbe()
+			if err != nil {
+				continue
+			}
+		}
+		sent[port] = start
+	}
+
+	rtts := make(map[int]time.Duration)
+	buf := make([]byte, 1500)
+	for len(rtts) < len(sent) && (maxOpen <= 0 || len(rtts) < maxOpen) {
+		n, from, err := conn.ReadFromUDP(buf)
+		if isConnRefused(err) {
+			continue // Windows reports a port unreachable without saying which port
+		}
+		if err != nil {
+			break // The deadline passed or the scan was cancelled
+		}
+		start, ok := sent[from.Port]
+		if _, seen := rtts[from.Port]; !ok || seen || n == 0 || !from.IP.Equal(dst.IP) {
+			continue
+		}
+		rtts[from.Port] = time.Since(start)
+		s.logger.Debug("udp port open", "ip", ip, "port", from.Port, "rtt", rtts[from.Port])
+	}
+
+	var results []UDPPortResult
+	opened := 0
+	for _, port := range s.UDPPorts {
+		if _, ok := sent[port]; !ok {
+			continue
+		}
+		result := UDPPortResult{Port: port, State: UDPOpenFiltered}
+		if rtt, ok := rtts[port]; ok {
+			if maxOpen > 0 && opened >= maxOpen {
+				continue
+			}
+			opened++
+			result.State, result.RTT = UDPOpen, rtt
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// listenUDP opens the unconnected socket of getUDPPortsShared, on SourceIP
+// and SourcePort when set
+func (s *Scanner) listenUDP(ctx context.Context, ipv4 bool) (*net.UDPConn, error) {
+	network := "udp6"
+	if ipv4 {
+		network = "udp4"
+	}
+	laddr := &net.UDPAddr{Port: s.SourcePort}
+	if s.SourceIP != nil && (s.SourceIP.To4() != nil) == ipv4 {
+		laddr.IP = s.SourceIP
+	}
+
+	var lc net.ListenConfig
+	if s.SourcePort > 0 {
+		lc.Control = func(_, _ string, c syscall.RawConn) error {
+			return setReuseAddr(c)
+		}
+	}
+	conn, err := lc.ListenPacket(ctx, network, laddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
@@ -1,34 +1,154 @@
 package main
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"neti/macaddr"
+
 	"github.com/jedib0t/go-pretty/v6/progress"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 )
 
 // UI handles user interface operations
 type UI struct {
-	progressWriter progress.Writer
-	tracker        *progress.Tracker
+	Format           string
+	Quiet            bool      // Suppress the progress bar and status messages
+	CountOnly        bool      // Print only the number of reachable hosts
+	ShowJitter       bool      // Show the RTT jitter column (only meaningful with several echoes per host)
+	ShowTries        bool      // Show how many echo requests each host needed (only meaningful with retries)
+	LatencyHistogram bool      // Summarize the ICMP response times in buckets
+	RankByPorts      bool      // Order hosts by open port count and show a ranking table
+	GroupBy          string    // Group table output by GroupByVendor or GroupBySubnet
+	MACFormat        string    // MAC address style, one of the MACFormat constants
+	JSONPretty       bool      // Indent JSON output instead of writing one line
+	Runs             int       // Scan runs merged with -repeat; above 1 adds a "Seen" column
+	Subnets          []string  // Scanned subnets, used when grouping by subnet
+	ProgressStream   io.Writer // Optional; receives every progress update as a JSON line
+	progressWriter   progress.Writer
+	tracker          *progress.Tracker
+
+	// progressMu guards the progress state below, so ShowProgress may be
+	// called from several goroutines at once
+	progressMu        sync.Mutex
+	logProgress       bool      // Write progress lines to stderr instead of drawing a bar
+	lastProgressLog   time.Time // When the last progress line was written
+	lastProgressStep  int       // Last 10% step that was logged
+	progressCompleted int       // Highest completed count reported so far
+	resolving         bool      // Progress is of the reverse DNS stage, not the scan
 }
 
+// Progress lines are logged every progressLogStep percent or progressLogInterval
+const (
+	progressLogStep     = 10
+	progressLogInterval = 5 * time.Second
+)
+
 // NewUI creates a new UI instance
 func NewUI() *UI {
-	return &UI{}
+	return &UI{Format: FormatTable, MACFormat: MACFormatColon}
 }
 
 // ShowUsage displays usage information
 func (ui *UI) ShowUsage(programName string) {
-	fmt.Printf("Usage: %s <subnet>\n", programName)
+	fmt.Printf("Usage: %s <subnet> [subnet...]\n", programName)
 	fmt.Printf("   or: %s -subnet=<subnet> [options]\n", programName)
+	fmt.Printf("   or: %s interfaces [-format=json]\n", programName)
+	fmt.Printf("   or: %s vendor [-format=json] <mac>...\n", programName)
+	fmt.Printf("   or: %s render [-format=dot] <results.json>\n", programName)
+	fmt.Printf("   or: %s ping [-count=N] [-interval=1s] <host>\n", programName)
 	fmt.Printf("Example: %s 192.168.1.0/24\n", programName)
+	fmt.Printf("Without a subnet, scans the local IPv4 subnets and the IPv6 neighbors found by multicast ping\n")
 	fmt.Printf("Options:\n")
-	fmt.Printf("  -tcp    Use TCP connect scan instead of ICMP ping\n")
-	fmt.Printf("  -udp    Perform UDP probe scan for common ports (open|filtered detection)\n")
+	fmt.Printf("  -local-subnet-of   Scan the connected subnet of the local interface that reaches this IP\n")
+	fmt.Printf("  -all-interfaces    Scan the subnet of every up interface from its own address, tagging hosts with it\n")
+	fmt.Printf("  -lldp              Listen for LLDP frames during the scan and show the system name and port of matching hosts\n")
+	fmt.Printf("  -lldp-wait         How long to listen for LLDP frames (default 30s, the usual advertisement interval)\n")
+	fmt.Printf("  -subnet-parallelism  Scan several subnets interleaved (default) or serial, one after another\n")
+	fmt.Printf("  -tcp               Use TCP connect scan instead of ICMP ping\n")
+	fmt.Printf("  -tcp-rst-up        With -tcp, count a refused connection (RST) as the host being up\n")
+	fmt.Printf("  -udp               Perform UDP probe scan for common ports (open|filtered detection)\n")
+	fmt.Printf("  -max-ports-per-host  Stop probing a host's ports once this many TCP and UDP ports are open\n")
+	fmt.Printf("  -udp-concurrency   UDP ports of one host to probe at once (default %d)\n", defaultUDPHostConcurrency)
+	fmt.Printf("  -udp-single-socket  Probe all UDP ports of a host from one socket, for dense scans; closed ports read as open|filtered\n")
+	fmt.Printf("  -udp-all           Probe UDP on every target, not only hosts that answered ICMP or TCP\n")
+	fmt.Printf("  -timeout           Per-probe timeout for every phase (default 500ms)\n")
+	fmt.Printf("  -ping-timeout      ICMP echo timeout (defaults to -timeout)\n")
+	fmt.Printf("  -tcp-timeout       TCP connect timeout (defaults to -timeout)\n")
+	fmt.Printf("  -port-timeout      TCP connect timeouts of slow ports, overriding -tcp-timeout (e.g. 3389=3s,25=2s)\n")
+	fmt.Printf("  -udp-timeout       UDP reply timeout (defaults to -timeout)\n")
+	fmt.Printf("  -warn-on-public-dns  Skip reverse lookups of private addresses that would go to a public DNS server\n")
+	fmt.Printf("  -resolve-timeout   Give up on a host's reverse DNS name after this long (default 1s)\n")
+	fmt.Printf("  -dns-ptr-batch     Resolve hostnames in a separate stage after the scan\n")
+	fmt.Printf("  -doh               Resolve hostnames over DNS-over-HTTPS with this server URL\n")
+	fmt.Printf("  -deadline          Stop the whole scan after this long and report what was found\n")
+	fmt.Printf("  -detect-foreign-replies  Report ping replies that come from a different source IP\n")
+	fmt.Printf("  -probe-size        ICMP echo payload size in bytes, for MTU testing (default 4)\n")
+	fmt.Printf("  -dont-fragment     Set the don't-fragment bit on ICMP echo requests\n")
+	fmt.Printf("  -icmp-fallback     Try ICMP timestamp and address mask requests on hosts that ignore ping\n")
+	fmt.Printf("  -icmp-per-host-socket  Open an ICMP socket for every host instead of one shared by the whole scan\n")
+	fmt.Printf("  -fail-fast-on-permission  Exit with code 3 at once if ICMP ping is not permitted, instead of scanning without it\n")
+	fmt.Printf("  -ports, -p         TCP ports to scan (e.g. 22,80,8000-8100)\n")
+	fmt.Printf("  -ports-file        Load the default TCP and UDP port lists from a file of \"name port/proto\" lines\n")
+	fmt.Printf("  -ports-from-services  TCP ports to scan by service name (e.g. ssh,http,smb); adds to -ports\n")
+	fmt.Printf("  -udp-ports         UDP ports to probe with -udp (e.g. 53,161)\n")
+	fmt.Printf("  -source-ip         Send all probes from this local address instead of the one the route picks\n")
+	fmt.Printf("  -source-port       Send TCP and UDP probes from this local port, for source-port ACLs\n")
+	fmt.Printf("  -socks5            Run the TCP connect scan through this SOCKS5 proxy (host:port); disables ICMP and UDP\n")
+	fmt.Printf("  -exclude-ports     Ports never to probe, same syntax as -ports\n")
+	fmt.Printf("  -vendor            Only show hosts whose manufacturer contains this text\n")
+	fmt.Printf("  -min-rtt           Only show hosts whose ICMP response time is at least this long\n")
+	fmt.Printf("  -max-rtt           Only show hosts whose ICMP response time is at most this long\n")
+	fmt.Printf("  -state             File of the hosts found by the previous scan, replaced after each scan\n")
+	fmt.Printf("  -only-new          With -state, only show hosts (IP and MAC) the previous scan did not find\n")
+	fmt.Printf("  -labels            CSV or JSON file of MAC or IP to a friendly label shown for matching hosts\n")
+	fmt.Printf("  -baseline          IP to MAC baseline file; flags changed and new MACs\n")
+	fmt.Printf("  -group-by          Group results by vendor or subnet\n")
+	fmt.Printf("  -sort              Order hosts by ip (default) or by discovery, first to answer first\n")
+	fmt.Printf("  -rank-by-ports     Rank hosts by their number of open ports, most first, to pick which to look at\n")
+	fmt.Printf("  -online-vendor     Look up vendors missing from the OUI file in an online API after the scan\n")
+	fmt.Printf("  -online-vendor-url MAC vendor API for -online-vendor; the OUI is appended (default %s)\n", onlineVendorURL)
+	fmt.Printf("  -repeat            Run the scan N times back to back and show in how many runs each host answered\n")
+	fmt.Printf("  -include-network-broadcast  Also scan each subnet's network and broadcast addresses\n")
+	fmt.Printf("  -include-self      Probe this host's own addresses too instead of listing them unprobed\n")
+	fmt.Printf("  -sample            Probe only N random addresses of the targets and estimate how many hosts are up\n")
+	fmt.Printf("  -seed              Random seed for -sample, to repeat the same sample\n")
+	fmt.Printf("  -show-down         Also list the scanned IPs that did not answer, marked down\n")
+	fmt.Printf("  -first-only        Stop as soon as the first reachable host is found\n")
+	fmt.Printf("  -stop-after-found  Stop as soon as N reachable hosts are found and show those\n")
+	fmt.Printf("  -continue-on-error  Skip subnets that do not parse, resolve or route instead of exiting; list them at the end\n")
+	fmt.Printf("  -max-results       Keep a random sample of at most N reachable hosts, bounding memory on huge scans\n")
+	fmt.Printf("  -arp-timeout       How long to wait for the ARP table after each ARP request (default %s)\n", macaddr.DefaultARPTimeout)
+	fmt.Printf("  -arp-retries       Further ARP requests to send when a MAC is still unknown (default %d)\n", macaddr.DefaultARPRetries)
+	fmt.Printf("  -concurrency       Hosts to probe at once (default: picked from the CPU and target count)\n")
+	fmt.Printf("  -count             Number of ICMP echo requests per host; >1 also reports jitter\n")
+	fmt.Printf("  -retry-icmp        Resend unanswered ICMP echoes up to N times within the timeout\n")
+	fmt.Printf("  -no-oui            Skip vendor lookups and the OUI database download\n")
+	fmt.Printf("  -oui-full          Include each vendor's address and country from the OUI file in JSON output\n")
+	fmt.Printf("  -oui-url           Download the OUI vendor database from this URL instead of IEEE\n")
+	fmt.Printf("  -count-only        Print only the number of reachable hosts\n")
+	fmt.Printf("  -progress-fd       Also write progress as JSON lines to this file descriptor, for wrapping UIs\n")
+	fmt.Printf("  -quiet             Hide the progress bar and status messages\n")
+	fmt.Printf("  -watch             Repeat the scan at this interval until interrupted (e.g. 5m)\n")
+	fmt.Printf("  -output-dir        Save each scan's results to a timestamped file in this directory\n")
+	fmt.Printf("  -tui               Show a live, interactive full-screen view of the scan\n")
+	fmt.Printf("  -mac-format        Show MACs as colon, hyphen, cisco or bare (default colon)\n")
+	fmt.Printf("  -format            Output format: table, json, dot or prom (default table)\n")
+	fmt.Printf("  -latency-histogram  Summarize the hosts' ICMP response times in buckets (also in JSON)\n")
+	fmt.Printf("  -json-pretty       Indent JSON output for reading instead of one line per result\n")
+	fmt.Printf("Commands:\n")
+	fmt.Printf("  interfaces         List up network interfaces and suggested scan targets\n")
+	fmt.Printf("  vendor             Look up the manufacturer of one or more MAC addresses\n")
+	fmt.Printf("  render             Re-render results saved with -format json in another format\n")
+	fmt.Printf("  ping               Ping one host continuously, like the classic ping tool\n")
 }
 
 // ShowError displays an error message
@@ -36,18 +156,86 @@ func (ui *UI) ShowError(message string, err error) {
 	fmt.Printf("%s: %v\n", message, err)
 }
 
+// ShowWarning reports a non-fatal problem on stderr so it never mixes with results
+func (ui *UI) ShowWarning(message string, err error) {
+	fmt.Fprintf(os.Stderr, "\nWarning: %s: %v\n", message, err)
+}
+
+// ShowStatus reports progress information on stderr unless running quietly
+func (ui *UI) ShowStatus(format string, args ...any) {
+	if !ui.Quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
 // ShowScanStart displays scan initialization information
 func (ui *UI) ShowScanStart(subnet string, totalIPs int) {
-	fmt.Printf("Scanning subnet: %s\n", subnet)
-	fmt.Printf("Found %d IPs to scan\n", totalIPs)
+	ui.progressMu.Lock()
+	defer ui.progressMu.Unlock()
+	ui.progressCompleted = 0
+	ui.resolving = false
+	if ui.Quiet {
+		return
+	}
+
+	out, ok := ui.progressOutput()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(out, "Scanning subnet: %s\n", subnet)
+	fmt.Fprintf(out, "Found %d IPs to scan\n", totalIPs)
+	ui.startTracker(out, "Scanning", totalIPs)
+}
+
+// ShowResolveStart displays the start of the reverse DNS stage that follows
+// the scan with -dns-ptr-batch. Its progress is reported with ShowProgress.
+func (ui *UI) ShowResolveStart(total int) {
+	ui.stopProgress()
+
+	ui.progressMu.Lock()
+	defer ui.progressMu.Unlock()
+	ui.progressCompleted = 0
+	ui.resolving = true
+	if ui.Quiet {
+		return
+	}
+
+	out, ok := ui.progressOutput()
+	if !ok {
+		return
+	}
+	fmt.Fprintf(out, "\nResolving names of %d hosts\n", total)
+	ui.startTracker(out, "Resolving names", total)
+}
+
+// progressOutput returns where the progress bar is drawn, or false when
+// progress is logged as lines instead. Called with progressMu held.
+func (ui *UI) progressOutput() (io.Writer, bool) {
+	// Machine-readable output must not be mixed with progress text, so only
+	// log occasional progress lines to stderr
+	if ui.Format != FormatTable && !ui.CountOnly {
+		ui.logProgress = true
+		ui.lastProgressLog = time.Now()
+		ui.lastProgressStep = 0
+		return nil, false
+	}
+
+	// In count-only mode stdout carries nothing but the final number
+	if ui.CountOnly {
+		return os.Stderr, true
+	}
+	return os.Stdout, true
+}
 
+// startTracker draws a progress bar on out. Called with progressMu held.
+func (ui *UI) startTracker(out io.Writer, message string, total int) {
 	ui.tracker = &progress.Tracker{
-		Message: "Scanning",
-		Total:   int64(totalIPs),
+		Message: message,
+		Total:   int64(total),
 		Units:   progress.UnitsDefault,
 	}
 	ui.progressWriter = progress.NewWriter()
-	ui.progressWriter.SetOutputWriter(os.Stdout)
+	ui.progressWriter.SetOutputWriter(out)
 	ui.progressWriter.SetStyle(progress.StyleBlocks)
 	ui.progressWriter.Style().Visibility.ETA = true
 	ui.progressWriter.Style().Options.TimeInProgressPrecision = time.Second
@@ -55,11 +243,331 @@ func (ui *UI) ShowScanStart(subnet string, totalIPs int) {
 	go ui.progressWriter.Render()
 }
 
-// ShowProgress displays scanning progress
+// ShowProgress displays scanning progress. It is safe for concurrent use;
+// reports that arrive after a later one are ignored so progress never goes back.
 func (ui *UI) ShowProgress(completed, total, found int) {
+	ui.progressMu.Lock()
+	defer ui.progressMu.Unlock()
+
+	if completed < ui.progressCompleted {
+		return
+	}
+	ui.progressCompleted = completed
+
+	if ui.ProgressStream != nil {
+		event := progressEvent{Completed: completed, Total: total, Found: found}
+		if ui.resolving {
+			event.Stage = "resolve"
+		}
+		if err := json.NewEncoder(ui.ProgressStream).Encode(event); err != nil {
+			// The reader went away; stop sending it updates
+			ui.ProgressStream = nil
+		}
+	}
+
 	if ui.tracker != nil {
 		ui.tracker.SetValue(int64(completed))
 	}
+
+	if ui.logProgress && total > 0 {
+		percent := completed * 100 / total
+		step := percent / progressLogStep
+		if step > ui.lastProgressStep || time.Since(ui.lastProgressLog) >= progressLogInterval {
+			ui.lastProgressStep = step
+			ui.lastProgressLog = time.Now()
+			if ui.resolving {
+				fmt.Fprintf(os.Stderr, "resolved %d/%d (%d%%), %d named\n", completed, total, percent, found)
+			} else {
+				fmt.Fprintf(os.Stderr, "scanned %d/%d (%d%%), %d up\n", completed, total, percent, found)
+			}
+		}
+	}
+}
+
+// progressEvent is a progress update written to UI.ProgressStream
+type progressEvent struct {
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Found     int    `json:"found"`
+	Stage     string `json:"stage,omitempty"` // "resolve" during -dns-ptr-batch, empty while scanning
+}
+
+// renderHostTable renders hosts as a numbered table
+func (ui *UI) renderHostTable(w io.Writer, hosts []HostInfo, showPorts bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleColoredDark)
+
+	// Adjust headers based on the optional columns being shown
+	header := table.Row{"#", "IP Address"}
+	showLabels := hasLabels(hosts)
+	if showLabels {
+		header = append(header, "Label")
+	}
+	showInterfaces := hasInterfaces(hosts)
+	if showInterfaces {
+		header = append(header, "Interface")
+	}
+	showRequested := hasRequestedNames(hosts)
+	if showRequested {
+		header = append(header, "Requested Name")
+	}
+	header = append(header, "Hostname", "MAC Address", "Manufacturer")
+	showVirtual := hasVirtualMACs(hosts)
+	if showVirtual {
+		header = append(header, "Virtual")
+	}
+	showLLDP := hasLLDP(hosts)
+	if showLLDP {
+		header = append(header, "LLDP")
+	}
+	if showPorts {
+		header = append(header, "Open Ports")
+	}
+	header = append(header, "ICMP Time")
+	if ui.ShowJitter {
+		header = append(header, "Jitter")
+	}
+	if ui.ShowTries {
+		header = append(header, "Tries")
+	}
+	if ui.Runs > 1 {
+		header = append(header, "Seen")
+	}
+	header = append(header, "Process Time")
+	showNotes := hasNotes(hosts)
+	if showNotes {
+		header = append(header, "Notes")
+	}
+	t.AppendHeader(header)
+
+	// Grey out unreachable IPs listed with -show-down
+	t.SetRowPainter(table.RowPainterWithAttributes(func(_ table.Row, attr table.RowAttributes) text.Colors {
+		if !hosts[attr.Number-1].Up {
+			return text.Colors{text.BgBlack, text.FgHiBlack}
+		}
+		return nil
+	}))
+
+	for i, host := range hosts {
+		mac := formatMAC(host.MAC, ui.MACFormat)
+		vendor := vendorLabel(mac)
+		processTimeStr := formatProcessTime(host.ProcessTime)
+		icmpTimeStr := formatResponseTime(host)
+		if !host.Up {
+			icmpTimeStr = "down"
+		}
+
+		// Handle empty fields for TCP-only hosts
+		if mac == "" {
+			mac = "N/A"
+		}
+		if host.Hostname == "" {
+			host.Hostname = "N/A"
+		}
+		if vendor == "" {
+			vendor = "N/A"
+		}
+
+		row := table.Row{i + 1, host.IP}
+		if showLabels {
+			row = append(row, cmp.Or(host.Label, "-"))
+		}
+		if showInterfaces {
+			row = append(row, cmp.Or(host.Interface, "-"))
+		}
+		if showRequested {
+			row = append(row, cmp.Or(host.RequestedName, "N/A"))
+		}
+		row = append(row, host.Hostname, mac, vendor)
+		if showVirtual {
+			row = append(row, cmp.Or(virtualLabel(host.MAC), "-"))
+		}
+		if showLLDP {
+			row = append(row, cmp.Or(formatLLDP(host), "-"))
+		}
+		if showPorts {
+			// Format open ports as comma-separated string
+			row = append(row, formatPorts(host.OpenPorts, host.OpenUDPPorts))
+		}
+		row = append(row, icmpTimeStr)
+		if ui.ShowJitter {
+			row = append(row, formatJitter(host.RTTJitter))
+		}
+		if ui.ShowTries {
+			row = append(row, host.ICMPTries)
+		}
+		if ui.Runs > 1 {
+			row = append(row, fmt.Sprintf("%d/%d", host.SeenRuns, ui.Runs))
+		}
+		row = append(row, processTimeStr)
+		if showNotes {
+			row = append(row, strings.Join(host.Notes, "; "))
+		}
+		t.AppendRow(row)
+	}
+
+	t.Render()
+}
+
+// hasLabels reports whether any host matched an entry of a -labels file
+func hasLabels(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if host.Label != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasInterfaces reports whether any host is tagged with the interface it was reached on
+func hasInterfaces(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if host.Interface != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRequestedNames reports whether any host was given as a hostname target
+func hasRequestedNames(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if host.RequestedName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasVirtualMACs reports whether any host has a virtualization platform's MAC
+func hasVirtualMACs(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if virtualLabel(host.MAC) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLLDP reports whether any host was matched to an LLDP neighbor
+func hasLLDP(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if formatLLDP(host) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatLLDP joins the system name and port a host advertised over LLDP
+func formatLLDP(host HostInfo) string {
+	switch {
+	case host.LLDPSystemName == "":
+		return host.LLDPPort
+	case host.LLDPPort == "":
+		return host.LLDPSystemName
+	}
+	return host.LLDPSystemName + " (" + host.LLDPPort + ")"
+}
+
+// hasNotes reports whether any host carries annotations
+func hasNotes(hosts []HostInfo) bool {
+	for _, host := range hosts {
+		if len(host.Notes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// renderGroupedHosts renders one table per group followed by a per-group tally
+func (ui *UI) renderGroupedHosts(w io.Writer, hosts []HostInfo, showPorts bool) {
+	groups := groupHosts(hosts, ui.GroupBy, ui.Subnets)
+
+	for _, group := range groups {
+		fmt.Fprintf(w, "\n%s (%d hosts)\n", group.Name, len(group.Hosts))
+		ui.renderHostTable(w, group.Hosts, showPorts)
+	}
+
+	fmt.Fprintln(w)
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleColoredDark)
+	t.AppendHeader(table.Row{groupTitle(ui.GroupBy), "Hosts"})
+	for _, group := range groups {
+		t.AppendRow(table.Row{group.Name, len(group.Hosts)})
+	}
+	t.AppendFooter(table.Row{"Total", len(hosts)})
+	t.Render()
+}
+
+// renderPortRanking renders hosts ranked by open ports, with the count
+// first, so the hosts running the most services stand out in a large scan
+func (ui *UI) renderPortRanking(w io.Writer, hosts []HostInfo) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.SetStyle(table.StyleColoredDark)
+	t.AppendHeader(table.Row{"#", "IP Address", "Hostname", "Manufacturer", "Open", "Ports"})
+	for i, host := range hosts {
+		vendor := vendorLabel(formatMAC(host.MAC, ui.MACFormat))
+		t.AppendRow(table.Row{i + 1, host.IP, cmp.Or(host.Hostname, "N/A"), cmp.Or(vendor, "N/A"),
+			openPortCount(host), formatPorts(host.OpenPorts, host.OpenUDPPorts)})
+	}
+	t.Render()
+}
+
+// showFiltered notes how many reachable hosts were hidden by result filters
+func (ui *UI) showFiltered(w io.Writer, result *ScanResult) {
+	if result.Filtered > 0 {
+		fmt.Fprintf(w, "(%d reachable hosts hidden by filters)\n", result.Filtered)
+	}
+	if result.Dropped > 0 {
+		fmt.Fprintf(w, "(Showing a random sample of %d of %d reachable hosts, -max-results)\n",
+			onlineHosts(result.ReachableHosts), onlineHosts(result.ReachableHosts)+result.Dropped)
+	}
+}
+
+// showProbeStats prints how many probes the scan sent and roughly how much traffic they made
+func (ui *UI) showProbeStats(w io.Writer, stats ProbeStats) {
+	fmt.Fprintf(w, "Probes sent: %d ICMP, %d TCP, %d UDP (~%s)\n", stats.ICMP, stats.TCP, stats.UDP, formatBytes(stats.Bytes))
+	if stats.Errors > 0 {
+		fmt.Fprintf(w, "Probe errors: %d hosts could not be pinged and may be up\n", stats.Errors)
+	}
+	if stats.Failed > 0 {
+		fmt.Fprintf(w, "Failed hosts: %d hosts were skipped after a probe crashed\n", stats.Failed)
+	}
+}
+
+// formatBytes formats a byte count using binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// stopProgress finishes the progress bar so it is not redrawn over the results,
+// which matters when a scan ends before every IP was probed
+func (ui *UI) stopProgress() {
+	ui.progressMu.Lock()
+	defer ui.progressMu.Unlock()
+
+	if ui.tracker == nil {
+		return
+	}
+	ui.tracker.MarkAsDone()
+	ui.progressWriter.Stop()
+	for ui.progressWriter.IsRenderInProgress() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ui.tracker = nil
 }
 
 func formatProcessTime(d time.Duration) string {
@@ -86,67 +594,202 @@ func formatICMPTime(d time.Duration) string {
 	return fmt.Sprintf("%dms", ms)
 }
 
-// formatPorts formats a slice of port numbers as a comma-separated string
-func formatPorts(ports []int) string {
-	if len(ports) == 0 {
+// formatResponseTime formats the host's response time, marking a TCP handshake
+// time used for hosts that did not answer ICMP
+func formatResponseTime(host HostInfo) string {
+	if host.ResponseTime() == 0 {
+		return "N/A"
+	}
+	if host.ICMPResponseTime == 0 && len(host.TCPPortResults) > 0 {
+		return formatICMPTime(host.ResponseTime()) + " (tcp)"
+	}
+	return formatICMPTime(host.ICMPResponseTime)
+}
+
+// formatJitter formats RTT jitter with microsecond precision below one millisecond
+func formatJitter(d time.Duration) string {
+	if d < time.Millisecond {
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	}
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// formatPorts lists TCP ports by number and UDP ports with a "/udp" suffix
+func formatPorts(tcpPorts, udpPorts []int) string {
+	if len(tcpPorts) == 0 && len(udpPorts) == 0 {
 		return "None"
 	}
 
 	var portStrs []string
-	for _, port := range ports {
+	for _, port := range tcpPorts {
 		portStrs = append(portStrs, fmt.Sprintf("%d", port))
 	}
+	for _, port := range udpPorts {
+		portStrs = append(portStrs, fmt.Sprintf("%d/udp", port))
+	}
 
 	return strings.Join(portStrs, ",")
 }
 
 // ShowResults displays the final scan results.
 func (ui *UI) ShowResults(result *ScanResult, showPorts bool) {
-	fmt.Println() // New line after progress
+	ui.stopProgress()
 
-	if len(result.ReachableHosts) == 0 {
-		fmt.Println("\nNo reachable hosts found.")
-		fmt.Println("Scan complete.")
-		return
+	if ui.Format == FormatTable && !ui.CountOnly {
+		fmt.Println() // New line after progress
 	}
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleColoredDark)
+	if err := ui.writeResults(os.Stdout, result, showPorts); err != nil {
+		ui.ShowError("Error writing results", err)
+	}
+}
 
-	// Adjust headers based on whether we're showing ports
-	if showPorts {
-		t.AppendHeader(table.Row{"#", "IP Address", "Hostname", "MAC Address", "Manufacturer", "Open Ports", "ICMP Time", "Process Time"})
+// SaveResults writes the scan results to a file in dir named after the scan
+// start time and returns its path. Prometheus metrics always go to the same
+// file, replaced only once complete so a scrape never reads half of it.
+func (ui *UI) SaveResults(dir string, started time.Time, result *ScanResult, showPorts bool) (string, error) {
+	path := filepath.Join(dir, started.Format(outputFileLayout)+formatExtension(ui.Format))
+	target := path
+	if ui.Format == FormatProm {
+		target = filepath.Join(dir, promFileName)
+		path = target + ".tmp"
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = ui.writeResults(file, result, showPorts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && target != path {
+		err = os.Rename(path, target)
+	}
+	return target, err
+}
+
+// writeResults renders the scan results to w in the selected format
+func (ui *UI) writeResults(w io.Writer, result *ScanResult, showPorts bool) error {
+	if ui.CountOnly {
+		_, err := fmt.Fprintln(w, onlineHosts(result.ReachableHosts))
+		return err
+	}
+
+	if ui.RankByPorts {
+		rankByPorts(result.ReachableHosts)
+	}
+
+	switch ui.Format {
+	case FormatJSON:
+		out := newJSONResult(result, ui.ShowJitter, ui.ShowTries, ui.MACFormat)
+		if ui.LatencyHistogram {
+			out.LatencyHistogram = latencyHistogram(result.ReachableHosts)
+		}
+		return writeJSON(w, out, ui.JSONPretty)
+	case FormatDOT:
+		return writeDOT(w, result, ui.MACFormat)
+	case FormatProm:
+		return writeProm(w, result, ui.MACFormat)
+	}
+
+	w = colorOutput(w)
+
+	if result.Meta != nil && !ui.Quiet {
+		fmt.Fprintf(w, "%s\n", result.Meta)
+		if result.Meta.Self != nil {
+			fmt.Fprintf(w, "%s\n", result.Meta.Self.withMACFormat(ui.MACFormat))
+		}
+	}
+
+	if len(result.ReachableHosts) == 0 {
+		fmt.Fprintln(w, "\nNo reachable hosts found.")
+		ui.showFiltered(w, result)
+		ui.showProbeStats(w, result.Probes)
+		if result.Completed < result.Total {
+			fmt.Fprintf(w, "Scan stopped early. (%d/%d IPs probed)\n", result.Completed, result.Total)
+			return nil
+		}
+		_, err := fmt.Fprintln(w, "Scan complete.")
+		return err
+	}
+
+	if ui.RankByPorts {
+		ui.renderPortRanking(w, result.ReachableHosts)
+	} else if ui.GroupBy != "" {
+		ui.renderGroupedHosts(w, result.ReachableHosts, showPorts)
 	} else {
-		t.AppendHeader(table.Row{"#", "IP Address", "Hostname", "MAC Address", "Manufacturer", "ICMP Time", "Process Time"})
+		ui.renderHostTable(w, result.ReachableHosts, showPorts)
 	}
 
-	for i, host := range result.ReachableHosts {
-		mac := host.MAC
-		vendor := mac2manufacturer(mac)
-		processTimeStr := formatProcessTime(host.ProcessTime)
-		icmpTimeStr := formatICMPTime(host.ICMPResponseTime)
+	ui.showFiltered(w, result)
+	if ui.LatencyHistogram {
+		writeLatencyHistogram(w, latencyHistogram(result.ReachableHosts))
+	}
+	ui.showProbeStats(w, result.Probes)
+	if result.Completed < result.Total {
+		fmt.Fprintf(w, "Scan stopped early. (%d hosts found, %d/%d IPs probed)\n", onlineHosts(result.ReachableHosts), result.Completed, result.Total)
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "Scan complete. (%d/%d hosts responded)\n", onlineHosts(result.ReachableHosts), result.Total)
+	return err
+}
 
-		// Handle empty fields for TCP-only hosts
+// ShowInterfaces displays the local network interfaces and their suggested scan targets
+func (ui *UI) ShowInterfaces(interfaces []InterfaceInfo) {
+	if ui.Format == FormatJSON {
+		if interfaces == nil {
+			interfaces = []InterfaceInfo{}
+		}
+		if err := writeJSON(os.Stdout, interfaces, ui.JSONPretty); err != nil {
+			ui.ShowError("Error writing JSON", err)
+		}
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(colorOutput(os.Stdout))
+	t.SetStyle(table.StyleColoredDark)
+	t.AppendHeader(table.Row{"Interface", "MAC Address", "Addresses", "Suggested Scan"})
+
+	for _, iface := range interfaces {
+		mac := formatMAC(iface.MAC, ui.MACFormat)
 		if mac == "" {
 			mac = "N/A"
 		}
-		if host.Hostname == "" {
-			host.Hostname = "N/A"
+		target := iface.SuggestedScan
+		if target == "" {
+			target = "N/A"
 		}
-		if vendor == "" {
-			vendor = "N/A"
+		t.AppendRow(table.Row{iface.Name, mac, strings.Join(iface.Addresses, "\n"), target})
+	}
+
+	t.Render()
+}
+
+// ShowVendors displays the manufacturer found for each looked-up MAC address
+func (ui *UI) ShowVendors(vendors []VendorInfo) {
+	if ui.Format == FormatJSON {
+		if err := writeJSON(os.Stdout, vendors, ui.JSONPretty); err != nil {
+			ui.ShowError("Error writing JSON", err)
 		}
+		return
+	}
 
-		if showPorts {
-			// Format open ports as comma-separated string
-			portsStr := formatPorts(host.OpenPorts)
-			t.AppendRow(table.Row{i + 1, host.IP, host.Hostname, mac, vendor, portsStr, icmpTimeStr, processTimeStr})
-		} else {
-			t.AppendRow(table.Row{i + 1, host.IP, host.Hostname, mac, vendor, icmpTimeStr, processTimeStr})
+	fmt.Println()
+	t := table.NewWriter()
+	t.SetOutputMirror(colorOutput(os.Stdout))
+	t.SetStyle(table.StyleColoredDark)
+	t.AppendHeader(table.Row{"MAC Address", "Manufacturer"})
+
+	for _, v := range vendors {
+		vendor := cmp.Or(v.Manufacturer, v.Reason)
+		if vendor == "" {
+			vendor = "N/A"
 		}
+		t.AppendRow(table.Row{v.MAC, vendor})
 	}
 
 	t.Render()
-	fmt.Printf("Scan complete. (%d/%d hosts responded)\n", len(result.ReachableHosts), result.Total)
 }
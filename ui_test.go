@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer is a bytes.Buffer safe for the concurrent writes of a test
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// hammerProgress reports total completions from many goroutines at once,
+// while the scan is restarted and the bar stopped, as scans of several
+// subnets and the -dns-ptr-batch stage do. Run with -race.
+func hammerProgress(ui *UI, total int) {
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for completed := g; completed <= total; completed += 8 {
+				ui.ShowProgress(completed, total, completed/2)
+			}
+		}()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			ui.ShowScanStart("198.51.100.0/24", total)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			ui.stopProgress()
+		}
+	}()
+	wg.Wait()
+	ui.stopProgress()
+}
+
+func TestShowProgressConcurrent(t *testing.T) {
+	for _, format := range []string{FormatTable, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			ui := NewUI()
+			ui.Format = format
+			// The bar goes to stderr in count-only mode, keeping test output clean
+			ui.CountOnly = format == FormatTable
+			stream := &lockedBuffer{}
+			ui.ProgressStream = stream
+
+			ui.ShowScanStart("198.51.100.0/24", 254)
+			hammerProgress(ui, 254)
+
+			// Every streamed event must be a whole JSON line
+			decoder := json.NewDecoder(&stream.buf)
+			events := 0
+			for decoder.More() {
+				var event progressEvent
+				if err := decoder.Decode(&event); err != nil {
+					t.Fatalf("progress stream holds a broken event: %v", err)
+				}
+				if event.Total != 254 || event.Completed > event.Total {
+					t.Errorf("event %+v is out of range", event)
+				}
+				events++
+			}
+			if events == 0 {
+				t.Error("no progress events were streamed")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onlineVendorURL is the MAC vendor API queried with -online-vendor. The OUI,
+// e.g. "00:1A:2B", is appended to it and the vendor name comes back as text.
+const onlineVendorURL = "https://api.macvendors.com/"
+
+// onlineVendorFileName caches answers from the vendor API, including OUIs it
+// does not know, so each prefix is only ever looked up once
+const onlineVendorFileName = "oui_online.txt"
+
+// Vendor API limits. Public APIs allow about one request a second, and the
+// lookups made after a scan give up once onlineVendorBudget is spent.
+const (
+	onlineVendorTimeout  = 5 * time.Second
+	onlineVendorInterval = time.Second
+	onlineVendorBudget   = 30 * time.Second
+)
+
+// onlineVendorClient queries the vendor API
+var onlineVendorClient = &http.Client{Timeout: onlineVendorTimeout}
+
+// Vendors found online for OUIs missing from the OUI file, keyed by the
+// 6-digit prefix. Prefixes the API does not know map to "".
+var (
+	onlineVendors         map[string]string
+	loadOnlineVendorsOnce sync.Once
+)
+
+// loadOnlineVendors reads the answers cached by earlier runs
+func loadOnlineVendors() {
+	onlineVendors = make(map[string]string)
+	file, err := os.Open(onlineVendorFileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		prefix, vendor, ok := strings.Cut(scanner.Text(), "\t")
+		if ok && len(prefix) == 6 {
+			onlineVendors[prefix] = vendor
+		}
+	}
+}
+
+// lookupOnlineVendor returns the cached online answer for an OUI prefix
+func lookupOnlineVendor(prefix string) string {
+	loadOnlineVendorsOnce.Do(loadOnlineVendors)
+	return onlineVendors[prefix]
+}
+
+// resolveOnlineVendors looks up the OUIs of hosts that the OUI file does not
+// know in the vendor API at apiURL. It runs after the scan, so a slow or
+// unreachable API never holds it up, and the answers are cached for later runs.
+func resolveOnlineVendors(ui *UI, apiURL string, hosts []HostInfo) {
+	loadOnlineVendorsOnce.Do(loadOnlineVendors)
+
+	var prefixes []string
+	queued := make(map[string]bool)
+	for _, host := range hosts {
+		if host.MAC == "" || mac2manufacturer(host.MAC) != "" || vendorReason(host.MAC) == vendorRandomized {
+			continue
+		}
+		prefix := normalizeMAC(host.MAC)[:6]
+		if _, cached := onlineVendors[prefix]; cached || queued[prefix] {
+			continue
+		}
+		queued[prefix] = true
+		prefixes = append(prefixes, prefix)
+	}
+	if len(prefixes) == 0 {
+		return
+	}
+
+	ui.ShowStatus("(Looking up %d vendors online...)", len(prefixes))
+	deadline := time.Now().Add(onlineVendorBudget)
+	var found map[string]string
+	for i, prefix := range prefixes {
+		if i > 0 {
+			time.Sleep(onlineVendorInterval)
+		}
+		if time.Now().After(deadline) {
+			ui.ShowWarning("Some vendors were not looked up online", fmt.Errorf("gave up after %s", onlineVendorBudget))
+			break
+		}
+
+		vendor, err := queryOnlineVendor(apiURL, prefix)
+		if err != nil {
+			ui.ShowWarning("Some vendors were not looked up online", err)
+			break
+		}
+		if found == nil {
+			found = make(map[string]string)
+		}
+		found[prefix] = vendor
+	}
+	if len(found) == 0 {
+		return
+	}
+
+	// Misses were memoized by mac2manufacturer, so forget them
+	vendorCacheMu.Lock()
+	for prefix, vendor := range found {
+		onlineVendors[prefix] = vendor
+	}
+	clear(vendorCache)
+	vendorCacheMu.Unlock()
+
+	if err := saveOnlineVendors(found); err != nil {
+		ui.ShowWarning("Online vendors will not be cached", err)
+	}
+}
+
+// queryOnlineVendor asks the vendor API for the manufacturer of an OUI prefix.
+// An OUI the API does not know is not an error and returns "".
+func queryOnlineVendor(apiURL, prefix string) (string, error) {
+	oui := prefix[0:2] + ":" + prefix[2:4] + ":" + prefix[4:6]
+	resp, err := onlineVendorClient.Get(apiURL + url.PathEscape(oui))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	case http.StatusTooManyRequests:
+		return "", fmt.Errorf("vendor API rate limit reached")
+	default:
+		return "", fmt.Errorf("vendor API returned status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	return vendorFromBody(string(body)), nil
+}
+
+// vendorFromBody takes the manufacturer from the first line of an API answer.
+// The cache holds one tab-separated entry per line, so no tab, carriage
+// return or newline may remain in it.
+func vendorFromBody(body string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	line = strings.NewReplacer("\t", " ", "\r", " ").Replace(line)
+	return strings.TrimSpace(line)
+}
+
+// saveOnlineVendors appends new answers to the online vendor cache file
+func saveOnlineVendors(found map[string]string) error {
+	file, err := os.OpenFile(onlineVendorFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for prefix, vendor := range found {
+		fmt.Fprintf(w, "%s\t%s\n", prefix, vendor)
+	}
+	err = w.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
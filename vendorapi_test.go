@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestVendorFromBody(t *testing.T) {
+	tests := []struct{ body, want string }{
+		{"Apple, Inc.", "Apple, Inc."},
+		{"  Apple, Inc.\n", "Apple, Inc."},
+		{"Apple, Inc.\r\n", "Apple, Inc."},
+		{"Apple,\tInc.", "Apple, Inc."},
+		{"Apple, Inc.\nCupertino CA 95014\nUS", "Apple, Inc."},
+		{"Apple, Inc.\r\nCupertino", "Apple, Inc."},
+		{"Odd\rVendor", "Odd Vendor"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := vendorFromBody(tt.body); got != tt.want {
+			t.Errorf("vendorFromBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
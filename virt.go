@@ -0,0 +1,41 @@
+package main
+
+import "strings"
+
+// virtualMACPrefixes are MAC prefixes assigned to virtualization platforms,
+// as normalized hex digits. They are built in so VMs and containers are
+// recognized even without the OUI database.
+var virtualMACPrefixes = []struct {
+	prefix string
+	label  string
+}{
+	{"000569", "VMware VM"},
+	{"000C29", "VMware VM"},
+	{"001C14", "VMware VM"},
+	{"005056", "VMware VM"},
+	{"080027", "VirtualBox VM"},
+	{"0A0027", "VirtualBox host-only adapter"},
+	{"00155D", "Hyper-V VM"},
+	{"0003FF", "Virtual PC VM"},
+	{"00163E", "Xen VM"},
+	{"525400", "QEMU/KVM VM"},
+	{"001C42", "Parallels VM"},
+	{"BC2411", "Proxmox VM"},
+	{"0242", "Docker container"}, // Docker derives the rest from the container IP
+}
+
+// virtualLabel returns the virtualization platform a MAC belongs to, e.g.
+// "VMware VM", or "" for MACs outside the known ranges
+func virtualLabel(mac string) string {
+	key := normalizeMAC(mac)
+	if len(key) != 12 {
+		return ""
+	}
+
+	for _, p := range virtualMACPrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.label
+		}
+	}
+	return ""
+}
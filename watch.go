@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// offlineCycles is how many watch cycles a host may miss before it is dropped
+// from the results. Until then it is shown as offline, so a single lost packet
+// does not make it flicker out of view.
+const offlineCycles = 3
+
+// watchedHost is a host remembered across watch cycles
+type watchedHost struct {
+	host   HostInfo
+	missed int // Consecutive cycles without a reply
+}
+
+// hostHistory merges the results of successive watch cycles
+type hostHistory struct {
+	hosts map[string]*watchedHost
+}
+
+func newHostHistory() *hostHistory {
+	return &hostHistory{hosts: make(map[string]*watchedHost)}
+}
+
+// merge records the hosts found in result at now and adds the hosts seen in
+// recent cycles that did not answer this time, marked offline. Those replace
+// their down entry when down hosts are recorded.
+func (h *hostHistory) merge(result *ScanResult, now time.Time) {
+	found := make(map[string]bool, len(result.ReachableHosts))
+	down := make(map[string]int)
+	for i := range result.ReachableHosts {
+		host := &result.ReachableHosts[i]
+		if !host.Up {
+			down[host.IP] = i
+			continue
+		}
+		found[host.IP] = true
+
+		host.FirstSeen, host.LastSeen = now, now
+		if prev, ok := h.hosts[host.IP]; ok {
+			host.FirstSeen = prev.host.FirstSeen
+		}
+		h.hosts[host.IP] = &watchedHost{host: *host}
+	}
+
+	for ip, w := range h.hosts {
+		if found[ip] {
+			continue
+		}
+		w.missed++
+		if w.missed > offlineCycles {
+			delete(h.hosts, ip)
+			continue
+		}
+
+		host := w.host
+		host.Offline = true
+		host.Seq = 0 // Not found in this scan
+		host.Notes = append(slices.Clip(host.Notes), "offline, last seen "+host.LastSeen.Format(time.TimeOnly))
+		if i, ok := down[ip]; ok {
+			result.ReachableHosts[i] = host
+			continue
+		}
+		result.ReachableHosts = append(result.ReachableHosts, host)
+	}
+
+	sort.Slice(result.ReachableHosts, func(i, j int) bool {
+		return lessIP(result.ReachableHosts[i].IP, result.ReachableHosts[j].IP)
+	})
+}
+
+// onlineHosts counts the hosts that answered in the latest scan
+func onlineHosts(hosts []HostInfo) int {
+	n := 0
+	for _, host := range hosts {
+		if host.Up && !host.Offline {
+			n++
+		}
+	}
+	return n
+}